@@ -0,0 +1,527 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// captureFile is the path of the bundle written by ktail capture
+var captureFile string
+
+// captureTimeLayout stamps the default bundle name and its top-level directory
+const captureTimeLayout = "20060102-150405"
+
+var captureCmd = &cobra.Command{
+	Use:   "capture [TYPE/NAME ...]",
+	Short: "Snapshot logs, pod specs and events of matching pods into a tar.gz bundle",
+	Long: `capture collects everything needed for a postmortem into a single tar.gz:
+the current and previous logs of every container, the pod specs and container
+statuses, and the events of the namespaces involved, indexed by manifest.json.
+
+Pods are selected like in ktail itself, by namespace, label selector or workload.
+Every container is captured unless -c, --container-regex or --all-containers is given.
+
+Examples:
+  ktail capture -n shop -l app=checkout --since 1h
+  ktail capture -n shop deploy/checkout --since-time 09:00 --until 09:30
+  ktail capture -A -l tier=edge -f edge-incident.tar.gz`,
+	Run: runCapture,
+}
+
+func init() {
+	captureCmd.Flags().StringVarP(&captureFile, "file", "f", "", "Bundle to write (default ktail-capture-<time>.tar.gz)")
+	rootCmd.AddCommand(captureCmd)
+}
+
+// captureManifest indexes the contents of a capture bundle
+type captureManifest struct {
+	CreatedAt  time.Time        `json:"createdAt"`
+	Clusters   []string         `json:"clusters"`
+	Namespaces []string         `json:"namespaces"`
+	Selector   string           `json:"selector,omitempty"`
+	Workloads  []string         `json:"workloads,omitempty"`
+	Since      string           `json:"since,omitempty"`
+	Until      string           `json:"until,omitempty"`
+	Pods       []capturedPod    `json:"pods"`
+	Events     []capturedEvents `json:"events"`
+	Errors     []string         `json:"errors,omitempty"`
+}
+
+// capturedPod describes a pod in the bundle
+type capturedPod struct {
+	Cluster    string              `json:"cluster,omitempty"`
+	Namespace  string              `json:"namespace"`
+	Name       string              `json:"name"`
+	Node       string              `json:"node,omitempty"`
+	Phase      string              `json:"phase"`
+	Spec       string              `json:"spec"`
+	Containers []capturedContainer `json:"containers"`
+}
+
+// capturedContainer describes a container of a captured pod and where its logs are
+type capturedContainer struct {
+	Name         string `json:"name"`
+	Init         bool   `json:"init,omitempty"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restartCount"`
+	State        string `json:"state"`
+	Logs         string `json:"logs,omitempty"`
+	PreviousLogs string `json:"previousLogs,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// capturedEvents points to the events of a namespace in the bundle
+type capturedEvents struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	File      string `json:"file"`
+	Count     int    `json:"count"`
+}
+
+func runCapture(cmd *cobra.Command, args []string) {
+	clusters, err := createK8sClients()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create Kubernetes client: %v\n", err)
+		os.Exit(1)
+	}
+
+	parseSelectionFlags(cmd)
+
+	// A postmortem wants every container unless some were picked explicitly
+	if container == "" && containerRegex == nil && !cmd.Flags().Changed("all-containers") {
+		allContainers = true
+		initContainers = true
+	}
+	// The whole window is captured unless a tail limit is given explicitly
+	if !cmd.Flags().Changed("tail") {
+		tailLines = -1
+	}
+
+	targetNamespaces := resolveTargetNamespaces(clusters)
+
+	now := time.Now()
+	if captureFile == "" {
+		captureFile = "ktail-capture-" + now.Format(captureTimeLayout) + ".tar.gz"
+	}
+
+	manifest := &captureManifest{
+		CreatedAt:  now.UTC(),
+		Namespaces: targetNamespaces,
+		Selector:   selector,
+		Workloads:  args,
+	}
+	if sinceDuration > 0 {
+		manifest.Since = now.Add(-sinceDuration).UTC().Format(time.RFC3339)
+	} else if !sinceTime.IsZero() {
+		manifest.Since = sinceTime.UTC().Format(time.RFC3339)
+	}
+	if !untilTime.IsZero() {
+		manifest.Until = untilTime.UTC().Format(time.RFC3339)
+	}
+
+	file, err := os.Create(captureFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create bundle: %v\n", err)
+		os.Exit(1)
+	}
+	bundle := newCaptureBundle(file, "ktail-capture-"+now.Format(captureTimeLayout), now)
+
+	showCluster := len(clusters) > 1
+	for _, cluster := range clusters {
+		manifest.Clusters = append(manifest.Clusters, cluster.Name)
+
+		pods, err := listCapturePods(cluster.Clientset, targetNamespaces, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to collect pods from %s: %v\n", cluster.Name, err)
+			os.Exit(1)
+		}
+		printStatus("Capturing %d pod(s) from %s\n", len(pods), cluster.Name)
+
+		clusterDir := ""
+		if showCluster {
+			clusterDir = safePathComponent(cluster.Name)
+		}
+		capture := &clusterCapture{
+			clientset: cluster.Clientset,
+			cluster:   cluster.Name,
+			dir:       clusterDir,
+			bundle:    bundle,
+			manifest:  manifest,
+		}
+		if err := capture.run(pods); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write bundle: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := bundle.addJSON("manifest.json", manifest); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write bundle: %v\n", err)
+		os.Exit(1)
+	}
+	if err := bundle.close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write bundle: %v\n", err)
+		os.Exit(1)
+	}
+
+	printStatus("Captured %d pod(s) into %s\n", len(manifest.Pods), captureFile)
+	if len(manifest.Errors) > 0 {
+		fmt.Fprintf(os.Stderr, "%d item(s) could not be captured, see manifest.json\n", len(manifest.Errors))
+	}
+}
+
+// listCapturePods lists the pods to capture, those of the given workloads or matching the label selector
+func listCapturePods(clientset *kubernetes.Clientset, namespaces []string, workloads []string) ([]corev1.Pod, error) {
+	if len(workloads) == 0 {
+		return listPods(clientset, namespaces, selector)
+	}
+
+	var pods []corev1.Pod
+	seen := make(map[string]bool)
+	for _, ns := range namespaces {
+		if ns == metav1.NamespaceAll {
+			return nil, fmt.Errorf("workloads cannot be resolved across all namespaces")
+		}
+		for _, ref := range workloads {
+			workloadSelector, err := getWorkloadSelector(clientset, ns, ref)
+			if err != nil {
+				return nil, err
+			}
+			matched, err := listPods(clientset, []string{ns}, mergeSelectors(workloadSelector, selector))
+			if err != nil {
+				return nil, err
+			}
+			// Pods matching several workloads are only captured once
+			for _, pod := range matched {
+				if key := pod.Namespace + "/" + pod.Name; !seen[key] {
+					seen[key] = true
+					pods = append(pods, pod)
+				}
+			}
+		}
+	}
+	return pods, nil
+}
+
+// clusterCapture writes the pods and events of one cluster into a bundle
+type clusterCapture struct {
+	clientset *kubernetes.Clientset
+	cluster   string
+	dir       string
+	bundle    *captureBundle
+	manifest  *captureManifest
+}
+
+// run captures the given pods and the events of their namespaces
+func (c *clusterCapture) run(pods []corev1.Pod) error {
+	namespaces := make(map[string]bool)
+	for i := range pods {
+		namespaces[pods[i].Namespace] = true
+		if err := c.capturePod(&pods[i]); err != nil {
+			return err
+		}
+	}
+
+	var names []string
+	for ns := range namespaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+	for _, ns := range names {
+		if err := c.captureEvents(ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// capturePod writes a pod's spec and the logs of its selected containers
+func (c *clusterCapture) capturePod(pod *corev1.Pod) error {
+	podCopy := pod.DeepCopy()
+	podCopy.ManagedFields = nil
+	specPath := path.Join(c.dir, "pods", pod.Namespace, pod.Name+".json")
+	if err := c.bundle.addJSON(specPath, podCopy); err != nil {
+		return err
+	}
+
+	captured := capturedPod{
+		Cluster:   c.cluster,
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Node:      pod.Spec.NodeName,
+		Phase:     string(pod.Status.Phase),
+		Spec:      c.bundle.entryPath(specPath),
+	}
+
+	for _, name := range getContainerNames(pod) {
+		container := describeCapturedContainer(pod, name)
+		logDir := path.Join(c.dir, "logs", pod.Namespace, pod.Name)
+
+		current, previous := capturedLogKinds(getContainerStatus(pod, name))
+		if current {
+			logPath := path.Join(logDir, name+".log")
+			if err := c.captureLogs(pod, name, false, logPath); err != nil {
+				if bundleErr, ok := err.(bundleError); ok {
+					return bundleErr.err
+				}
+				container.Error = err.Error()
+				c.recordError("%s/%s (container: %s) logs: %v", pod.Namespace, pod.Name, name, err)
+			} else {
+				container.Logs = c.bundle.entryPath(logPath)
+			}
+		}
+
+		if previous {
+			previousPath := path.Join(logDir, name+".previous.log")
+			if err := c.captureLogs(pod, name, true, previousPath); err != nil {
+				if bundleErr, ok := err.(bundleError); ok {
+					return bundleErr.err
+				}
+				c.recordError("%s/%s (container: %s) previous logs: %v", pod.Namespace, pod.Name, name, err)
+			} else {
+				container.PreviousLogs = c.bundle.entryPath(previousPath)
+			}
+		}
+
+		captured.Containers = append(captured.Containers, container)
+	}
+
+	c.manifest.Pods = append(c.manifest.Pods, captured)
+	return nil
+}
+
+// capturedLogKinds reports which logs of a container can be captured: the current
+// instance's once it has started, and the previous instance's after any restart,
+// even while the container waits in CrashLoopBackOff
+func capturedLogKinds(status *corev1.ContainerStatus) (current, previous bool) {
+	if status == nil {
+		return false, false
+	}
+	current = status.State.Running != nil || status.State.Terminated != nil
+	return current, status.RestartCount > 0
+}
+
+// bundleError marks a failure to write the bundle itself, as opposed to a log that could not be read
+type bundleError struct{ err error }
+
+func (e bundleError) Error() string { return e.err.Error() }
+
+// captureLogs copies the current or previous logs of a container into the bundle
+func (c *clusterCapture) captureLogs(pod *corev1.Pod, containerName string, previous bool, name string) error {
+	opts := &corev1.PodLogOptions{
+		Container:  containerName,
+		Previous:   previous,
+		Timestamps: true,
+	}
+	applyTimeWindow(opts)
+
+	stream, err := c.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(context.TODO())
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	spool, size, err := spoolToTempFile(untilReader(stream))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	if err := c.bundle.addReader(name, spool, size); err != nil {
+		return bundleError{err}
+	}
+	return nil
+}
+
+// spoolToTempFile copies a stream into a temporary file, rewound for reading. Tar
+// headers need the size up front and logs may not fit in memory.
+func spoolToTempFile(r io.Reader) (*os.File, int64, error) {
+	spool, err := os.CreateTemp("", "ktail-capture-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := io.Copy(spool, r)
+	if err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, 0, err
+	}
+	return spool, size, nil
+}
+
+// captureEvents writes the events of a namespace into the bundle
+func (c *clusterCapture) captureEvents(namespace string) error {
+	events, err := c.clientset.CoreV1().Events(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		c.recordError("events of %s: %v", namespaceLabel(namespace), err)
+		return nil
+	}
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return eventTime(&events.Items[i]).Before(eventTime(&events.Items[j]))
+	})
+	for i := range events.Items {
+		events.Items[i].ManagedFields = nil
+	}
+
+	eventsPath := path.Join(c.dir, "events", namespace+".json")
+	if err := c.bundle.addJSON(eventsPath, events.Items); err != nil {
+		return err
+	}
+	c.manifest.Events = append(c.manifest.Events, capturedEvents{
+		Cluster:   c.cluster,
+		Namespace: namespace,
+		File:      c.bundle.entryPath(eventsPath),
+		Count:     len(events.Items),
+	})
+	return nil
+}
+
+// recordError reports an item that could not be captured and notes it in the manifest
+func (c *clusterCapture) recordError(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if c.cluster != "" && c.dir != "" {
+		message = c.cluster + ": " + message
+	}
+	fmt.Fprintf(os.Stderr, "Failed to capture %s\n", message)
+	c.manifest.Errors = append(c.manifest.Errors, message)
+}
+
+// describeCapturedContainer summarizes the status of a container for the manifest
+func describeCapturedContainer(pod *corev1.Pod, name string) capturedContainer {
+	container := capturedContainer{Name: name, Init: isInitContainer(pod, name), State: "Waiting"}
+	status := getContainerStatus(pod, name)
+	if status == nil {
+		container.State = "Unknown"
+		return container
+	}
+
+	container.Ready = status.Ready
+	container.RestartCount = status.RestartCount
+	switch {
+	case status.State.Running != nil:
+		container.State = "Running"
+	case status.State.Terminated != nil:
+		container.State = "Terminated (" + describeTermination(status.State.Terminated) + ")"
+	case status.State.Waiting != nil && status.State.Waiting.Reason != "":
+		container.State = "Waiting (" + status.State.Waiting.Reason + ")"
+	}
+	return container
+}
+
+// eventTime returns when an event last happened
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// untilReader cuts a timestamped log stream at the --until cut-off
+func untilReader(r io.Reader) io.Reader {
+	if untilTime.IsZero() {
+		return r
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+		for scanner.Scan() {
+			if ts, _, ok := splitTimestamp(scanner.Text()); ok && ts.After(untilTime) {
+				break
+			}
+			if _, err := io.WriteString(pw, scanner.Text()+"\n"); err != nil {
+				return
+			}
+		}
+		pw.CloseWithError(scanner.Err())
+	}()
+	return pr
+}
+
+// captureBundle writes files into a gzipped tar under a common top-level directory
+type captureBundle struct {
+	file    *os.File
+	gz      *gzip.Writer
+	tw      *tar.Writer
+	root    string
+	modTime time.Time
+}
+
+// newCaptureBundle starts a bundle in the given file
+func newCaptureBundle(file *os.File, root string, modTime time.Time) *captureBundle {
+	gz := gzip.NewWriter(file)
+	return &captureBundle{
+		file:    file,
+		gz:      gz,
+		tw:      tar.NewWriter(gz),
+		root:    root,
+		modTime: modTime,
+	}
+}
+
+// entryPath returns the path of a file inside the bundle
+func (b *captureBundle) entryPath(name string) string {
+	return path.Join(b.root, name)
+}
+
+// addJSON writes a value as indented JSON
+func (b *captureBundle) addJSON(name string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return b.addBytes(name, append(data, '\n'))
+}
+
+// addBytes writes a file held in memory
+func (b *captureBundle) addBytes(name string, data []byte) error {
+	return b.addReader(name, bytes.NewReader(data), int64(len(data)))
+}
+
+// addReader writes a file of the given size read from r
+func (b *captureBundle) addReader(name string, r io.Reader, size int64) error {
+	header := &tar.Header{
+		Name:    b.entryPath(name),
+		Mode:    0o644,
+		Size:    size,
+		ModTime: b.modTime,
+	}
+	if err := b.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.CopyN(b.tw, r, size)
+	return err
+}
+
+// close finishes the archive and the file holding it
+func (b *captureBundle) close() error {
+	if err := b.tw.Close(); err != nil {
+		return err
+	}
+	if err := b.gz.Close(); err != nil {
+		return err
+	}
+	return b.file.Close()
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCaptureBundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	bundle := newCaptureBundle(file, "ktail-capture-test", modTime)
+	if err := bundle.addJSON("manifest.json", map[string]string{"hello": "world"}); err != nil {
+		t.Fatal(err)
+	}
+	spool, size, err := spoolToTempFile(strings.NewReader("line 1\nline 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	if err := bundle.addReader("logs/shop/api-1/app.log", spool, size); err != nil {
+		t.Fatal(err)
+	}
+	if err := bundle.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readTarGz(t, path)
+	want := map[string]string{
+		"ktail-capture-test/manifest.json":           "{\n  \"hello\": \"world\"\n}\n",
+		"ktail-capture-test/logs/shop/api-1/app.log": "line 1\nline 2\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bundle contents = %v, want %v", got, want)
+	}
+}
+
+func TestDescribeCapturedContainer(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "migrate"}},
+			Containers:     []corev1.Container{{Name: "app"}, {Name: "sidecar"}, {Name: "pending"}},
+		},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "migrate",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"}},
+			}},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", Ready: true, RestartCount: 2, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+				{Name: "sidecar", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		want capturedContainer
+	}{
+		{"migrate", capturedContainer{Name: "migrate", Init: true, State: "Terminated (Completed, exit code 0)"}},
+		{"app", capturedContainer{Name: "app", Ready: true, RestartCount: 2, State: "Running"}},
+		{"sidecar", capturedContainer{Name: "sidecar", State: "Waiting (CrashLoopBackOff)"}},
+		{"pending", capturedContainer{Name: "pending", State: "Unknown"}},
+	}
+
+	for _, tt := range tests {
+		if got := describeCapturedContainer(pod, tt.name); got != tt.want {
+			t.Errorf("describeCapturedContainer(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCapturedLogKinds(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       *corev1.ContainerStatus
+		wantCurrent  bool
+		wantPrevious bool
+	}{
+		{"no status", nil, false, false},
+		{"running", &corev1.ContainerStatus{State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}, true, false},
+		{"running after restarts", &corev1.ContainerStatus{RestartCount: 2, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}, true, true},
+		{"terminated", &corev1.ContainerStatus{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}}, true, false},
+		{"waiting to start", &corev1.ContainerStatus{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}}}, false, false},
+		{"crash looping", &corev1.ContainerStatus{RestartCount: 5, State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}}, false, true},
+	}
+
+	for _, tt := range tests {
+		current, previous := capturedLogKinds(tt.status)
+		if current != tt.wantCurrent || previous != tt.wantPrevious {
+			t.Errorf("%s: capturedLogKinds() = %v, %v, want %v, %v", tt.name, current, previous, tt.wantCurrent, tt.wantPrevious)
+		}
+	}
+}
+
+func TestUntilReader(t *testing.T) {
+	defer func() { untilTime = time.Time{} }()
+	untilTime = time.Date(2024, 3, 1, 9, 0, 1, 0, time.UTC)
+
+	logs := "2024-03-01T09:00:00Z first\n2024-03-01T09:00:01Z second\n2024-03-01T09:00:02Z third\n"
+	got, err := io.ReadAll(untilReader(strings.NewReader(logs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-03-01T09:00:00Z first\n2024-03-01T09:00:01Z second\n"; string(got) != want {
+		t.Errorf("untilReader() = %q, want %q", got, want)
+	}
+}
+
+func TestEventTime(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	last := created.Add(time.Minute)
+
+	event := &corev1.Event{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)}}
+	if got := eventTime(event); !got.Equal(created) {
+		t.Errorf("eventTime() = %v, want the creation time %v", got, created)
+	}
+	event.LastTimestamp = metav1.NewTime(last)
+	if got := eventTime(event); !got.Equal(last) {
+		t.Errorf("eventTime() = %v, want the last timestamp %v", got, last)
+	}
+}
+
+func readTarGz(t *testing.T, path string) map[string]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := make(map[string]string)
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[header.Name] = string(data)
+	}
+	return contents
+}
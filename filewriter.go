@@ -0,0 +1,231 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeLayout stamps the names of rotated log files
+const rotatedTimeLayout = "20060102T150405"
+
+// logFileWriter saves every container stream to <dir>/[cluster/]<ns>/<pod>/<container>.log,
+// rotating the files by size and age and optionally compressing the rotated ones
+type logFileWriter struct {
+	dir         string
+	withCluster bool
+	maxSize     int64
+	maxAge      time.Duration
+	compress    bool
+	files       map[string]*logFile
+	compressing sync.WaitGroup
+	// now is the clock used for rotation, replaceable in tests
+	now func() time.Time
+}
+
+// logFile is an open log file of one container stream
+type logFile struct {
+	path   string
+	file   *os.File
+	size   int64
+	opened time.Time
+	failed bool
+}
+
+// newLogFileWriter creates a writer for --output-dir; maxSize in bytes and maxAge of zero disable that rotation
+func newLogFileWriter(dir string, withCluster bool, maxSize int64, maxAge time.Duration, compress bool) *logFileWriter {
+	return &logFileWriter{
+		dir:         dir,
+		withCluster: withCluster,
+		maxSize:     maxSize,
+		maxAge:      maxAge,
+		compress:    compress,
+		files:       make(map[string]*logFile),
+		now:         time.Now,
+	}
+}
+
+// logFilePath returns the path of the file a container stream is saved to
+func (w *logFileWriter) logFilePath(pod PodInfo) string {
+	parts := []string{w.dir}
+	if w.withCluster {
+		parts = append(parts, safePathComponent(pod.Cluster))
+	}
+	parts = append(parts, safePathComponent(pod.Namespace), safePathComponent(pod.Name), safePathComponent(pod.Container)+".log")
+	return filepath.Join(parts...)
+}
+
+// safePathComponent makes a name usable as a single path component; context
+// names in particular may contain slashes, as in EKS cluster ARNs
+func safePathComponent(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// write appends a log line to its stream's file, prefixed with its kubelet timestamp
+// like `kubectl logs --timestamps`. ktail's own markers are not saved.
+func (w *logFileWriter) write(logLine LogLine) {
+	if logLine.Time.IsZero() {
+		return
+	}
+
+	key := streamKey(logLine.PodInfo)
+	f, ok := w.files[key]
+	if !ok {
+		f = &logFile{path: w.logFilePath(logLine.PodInfo)}
+		w.files[key] = f
+	}
+	if f.failed {
+		return
+	}
+
+	line := logLine.Time.UTC().Format(time.RFC3339Nano) + " " + logLine.Line + "\n"
+	if err := w.writeLine(f, line); err != nil {
+		// Report a broken file once instead of for every line
+		fmt.Fprintf(os.Stderr, "Failed to write %s, no longer saving this stream: %v\n", f.path, err)
+		f.failed = true
+		if f.file != nil {
+			f.file.Close()
+			f.file = nil
+		}
+	}
+}
+
+// writeLine writes a line to a log file, opening or rotating it first when needed
+func (w *logFileWriter) writeLine(f *logFile, line string) error {
+	now := w.now()
+	if f.file != nil && w.needsRotation(f, int64(len(line)), now) {
+		if err := w.rotate(f, now); err != nil {
+			return err
+		}
+	}
+	if f.file == nil {
+		if err := w.open(f, now); err != nil {
+			return err
+		}
+	}
+
+	n, err := io.WriteString(f.file, line)
+	f.size += int64(n)
+	return err
+}
+
+// needsRotation reports whether a file must be rotated before writing n more bytes.
+// A file is never rotated empty, so a single oversized line still gets written.
+func (w *logFileWriter) needsRotation(f *logFile, n int64, now time.Time) bool {
+	if f.size == 0 {
+		return false
+	}
+	if w.maxSize > 0 && f.size+n > w.maxSize {
+		return true
+	}
+	return w.maxAge > 0 && now.Sub(f.opened) >= w.maxAge
+}
+
+// open opens a log file for appending, continuing an existing file
+func (w *logFileWriter) open(f *logFile, now time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.opened = now
+	return nil
+}
+
+// rotate closes a log file and moves it aside as <container>-<time>.log, compressing it in the background with --gzip
+func (w *logFileWriter) rotate(f *logFile, now time.Time) error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	base := strings.TrimSuffix(f.path, ".log")
+	rotated := base + "-" + now.Format(rotatedTimeLayout) + ".log"
+	for i := 1; fileExists(rotated) || fileExists(rotated+".gz"); i++ {
+		rotated = fmt.Sprintf("%s-%s.%d.log", base, now.Format(rotatedTimeLayout), i)
+	}
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+
+	if w.compress {
+		w.compressing.Add(1)
+		go func() {
+			defer w.compressing.Done()
+			if err := gzipFile(rotated); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to compress %s: %v\n", rotated, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// close closes every log file and waits for rotated files to be compressed
+func (w *logFileWriter) close() {
+	for _, f := range w.files {
+		if f.file != nil {
+			f.file.Close()
+			f.file = nil
+		}
+	}
+	w.compressing.Wait()
+}
+
+// fileExists reports whether a path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// gzipFile compresses a file to <path>.gz and removes the original
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(path)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	in.Close()
+	return os.Remove(path)
+}
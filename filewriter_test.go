@@ -0,0 +1,142 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLogFileWriterPaths(t *testing.T) {
+	tests := []struct {
+		name        string
+		withCluster bool
+		pod         PodInfo
+		want        string
+	}{
+		{"single cluster", false, PodInfo{Cluster: "prod", Namespace: "shop", Name: "api-1", Container: "app"}, "shop/api-1/app.log"},
+		{"several clusters", true, PodInfo{Cluster: "prod", Namespace: "shop", Name: "api-1", Container: "app"}, "prod/shop/api-1/app.log"},
+		{"cluster ARN", true, PodInfo{Cluster: "arn:aws:eks:eu-west-1:123:cluster/prod", Namespace: "shop", Name: "api-1", Container: "app"}, "arn_aws_eks_eu-west-1_123_cluster_prod/shop/api-1/app.log"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newLogFileWriter("out", tt.withCluster, 0, 0, false)
+			if got := w.logFilePath(tt.pod); got != filepath.Join("out", tt.want) {
+				t.Errorf("logFilePath() = %q, want %q", got, filepath.Join("out", tt.want))
+			}
+		})
+	}
+}
+
+func TestLogFileWriterWrite(t *testing.T) {
+	dir := t.TempDir()
+	w := newLogFileWriter(dir, false, 0, 0, false)
+	pod := PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}
+	ts := time.Date(2024, 3, 1, 9, 0, 0, 123000000, time.UTC)
+
+	w.write(LogLine{PodInfo: pod, Line: "=== Starting logs ==="})
+	w.write(LogLine{PodInfo: pod, Time: ts, Line: "first"})
+	w.write(LogLine{PodInfo: pod, Time: ts.Add(time.Second), Line: "second"})
+	w.close()
+
+	got := readFile(t, filepath.Join(dir, "shop", "api-1", "app.log"))
+	want := "2024-03-01T09:00:00.123Z first\n2024-03-01T09:00:01.123Z second\n"
+	if got != want {
+		t.Errorf("log file = %q, want %q", got, want)
+	}
+}
+
+func TestLogFileWriterRotation(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	w := newLogFileWriter(dir, false, 70, time.Hour, true)
+	w.now = func() time.Time { return now }
+	pod := PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}
+
+	// Each line takes 28 bytes, so the second one still fits and the third rotates by size
+	w.write(LogLine{PodInfo: pod, Time: now, Line: "line-1"})
+	w.write(LogLine{PodInfo: pod, Time: now, Line: "line-2"})
+	w.write(LogLine{PodInfo: pod, Time: now, Line: "line-3"})
+	// An hour later the file rotates by age
+	now = now.Add(time.Hour)
+	w.write(LogLine{PodInfo: pod, Time: now, Line: "line-4"})
+	w.close()
+
+	podDir := filepath.Join(dir, "shop", "api-1")
+	entries, err := os.ReadDir(podDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	wantNames := []string{"app-20240301T090000.log.gz", "app-20240301T100000.log.gz", "app.log"}
+	if len(names) != len(wantNames) {
+		t.Fatalf("files = %v, want %v", names, wantNames)
+	}
+	for i := range names {
+		if names[i] != wantNames[i] {
+			t.Fatalf("files = %v, want %v", names, wantNames)
+		}
+	}
+
+	if got := readGzipFile(t, filepath.Join(podDir, "app-20240301T090000.log.gz")); got != "2024-03-01T09:00:00Z line-1\n2024-03-01T09:00:00Z line-2\n" {
+		t.Errorf("first rotated file = %q", got)
+	}
+	if got := readGzipFile(t, filepath.Join(podDir, "app-20240301T100000.log.gz")); got != "2024-03-01T09:00:00Z line-3\n" {
+		t.Errorf("second rotated file = %q", got)
+	}
+	if got := readFile(t, filepath.Join(podDir, "app.log")); got != "2024-03-01T10:00:00Z line-4\n" {
+		t.Errorf("current file = %q", got)
+	}
+}
+
+func TestLogFileWriterAppendsToExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	pod := PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}
+	ts := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	for _, line := range []string{"before restart", "after restart"} {
+		w := newLogFileWriter(dir, false, 0, 0, false)
+		w.write(LogLine{PodInfo: pod, Time: ts, Line: line})
+		w.close()
+	}
+
+	got := readFile(t, filepath.Join(dir, "shop", "api-1", "app.log"))
+	if want := "2024-03-01T09:00:00Z before restart\n2024-03-01T09:00:00Z after restart\n"; got != want {
+		t.Errorf("log file = %q, want %q", got, want)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func readGzipFile(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
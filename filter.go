@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// compilePatterns compiles every regex given to a repeatable flag
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var regexes []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		regexes = append(regexes, re)
+	}
+	return regexes, nil
+}
+
+// lineFilter keeps the lines matching the include patterns and none of the
+// exclude patterns, along with up to before/after lines of context per stream
+type lineFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	before  int
+	after   int
+	streams map[string]*filterContext
+}
+
+// filterContext is the context state of a single log stream
+type filterContext struct {
+	// pending holds the latest non-matching lines, printed if a match follows
+	pending []LogLine
+	// remaining counts the lines still to print after the last match
+	remaining int
+}
+
+// newLineFilter creates a filter, or returns nil when no pattern is given
+func newLineFilter(include, exclude []*regexp.Regexp, before, after int) *lineFilter {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil
+	}
+	return &lineFilter{
+		include: include,
+		exclude: exclude,
+		before:  before,
+		after:   after,
+		streams: make(map[string]*filterContext),
+	}
+}
+
+// excluded reports whether a line matches an exclude pattern
+func (f *lineFilter) excluded(line string) bool {
+	for _, re := range f.exclude {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// included reports whether a line matches an include pattern, or any line without include patterns
+func (f *lineFilter) included(line string) bool {
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply returns the lines to print for an incoming line: nothing, the line
+// itself, or the line preceded by its context.
+// Excluded lines are never shown, not even as context, while ktail's own
+// markers carry no timestamp and always pass.
+func (f *lineFilter) apply(logLine LogLine) []LogLine {
+	if logLine.Time.IsZero() {
+		return []LogLine{logLine}
+	}
+	if f.excluded(logLine.Line) {
+		return nil
+	}
+
+	key := streamKey(logLine.PodInfo)
+	state, ok := f.streams[key]
+	if !ok {
+		state = &filterContext{}
+		f.streams[key] = state
+	}
+
+	if !f.included(logLine.Line) {
+		if state.remaining > 0 {
+			state.remaining--
+			return []LogLine{logLine}
+		}
+		if f.before > 0 {
+			state.pending = append(state.pending, logLine)
+			if len(state.pending) > f.before {
+				state.pending = state.pending[1:]
+			}
+		}
+		return nil
+	}
+
+	lines := append(state.pending, logLine)
+	state.pending = nil
+	state.remaining = f.after
+	return lines
+}
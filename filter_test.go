@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestLineFilterIncludeExclude(t *testing.T) {
+	filter := newLineFilter(
+		[]*regexp.Regexp{regexp.MustCompile("ERROR|WARN")},
+		[]*regexp.Regexp{regexp.MustCompile("healthz")},
+		0, 0,
+	)
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"ERROR connection refused", true},
+		{"WARN slow request", true},
+		{"INFO started", false},
+		{"ERROR GET /healthz", false},
+	}
+
+	ts := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		got := len(filter.apply(LogLine{Time: ts, Line: tt.line})) > 0
+		if got != tt.want {
+			t.Errorf("apply(%q) shown = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestLineFilterPassesMarkers(t *testing.T) {
+	filter := newLineFilter([]*regexp.Regexp{regexp.MustCompile("ERROR")}, nil, 0, 0)
+	marker := LogLine{Line: "=== Starting logs for default/api (container: app) ==="}
+	if got := filter.apply(marker); len(got) != 1 {
+		t.Errorf("apply(marker) = %v, want the marker", got)
+	}
+}
+
+func TestLineFilterContext(t *testing.T) {
+	filter := newLineFilter([]*regexp.Regexp{regexp.MustCompile("ERROR")}, nil, 2, 1)
+	ts := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	podA := PodInfo{Namespace: "default", Name: "a", Container: "app"}
+	podB := PodInfo{Namespace: "default", Name: "b", Container: "app"}
+
+	var got []string
+	for _, logLine := range []LogLine{
+		{PodInfo: podA, Time: ts, Line: "a1"},
+		{PodInfo: podA, Time: ts, Line: "a2"},
+		{PodInfo: podB, Time: ts, Line: "b1"},
+		{PodInfo: podA, Time: ts, Line: "a3"},
+		{PodInfo: podA, Time: ts, Line: "a4 ERROR"},
+		{PodInfo: podB, Time: ts, Line: "b2"},
+		{PodInfo: podA, Time: ts, Line: "a5"},
+		{PodInfo: podA, Time: ts, Line: "a6"},
+	} {
+		for _, line := range filter.apply(logLine) {
+			got = append(got, line.Line)
+		}
+	}
+
+	// Context is kept per stream: b1 and b2 never surround a match in pod b
+	want := []string{"a2", "a3", "a4 ERROR", "a5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filtered lines = %v, want %v", got, want)
+	}
+}
+
+func TestNewLineFilterWithoutPatterns(t *testing.T) {
+	if filter := newLineFilter(nil, nil, 3, 3); filter != nil {
+		t.Errorf("newLineFilter() = %v, want nil without patterns", filter)
+	}
+}
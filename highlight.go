@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Normalized severity levels recognized in log lines
+const (
+	levelError = "error"
+	levelWarn  = "warn"
+	levelInfo  = "info"
+	levelDebug = "debug"
+)
+
+// Supported --level-colors modes
+const (
+	levelColorsToken = "token"
+	levelColorsLine  = "line"
+	levelColorsOff   = "off"
+)
+
+// levelPatterns find the level token of a line: a JSON or logfmt level field,
+// or an upper case level word. The first submatch is the level itself.
+var levelPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`"(?:level|lvl|severity)"\s*:\s*"(\w+)"`),
+	regexp.MustCompile(`\b(?:level|lvl|severity)="?(\w+)`),
+	regexp.MustCompile(`\b(FATAL|PANIC|CRITICAL|ERROR|ERR|WARNING|WARN|INFO|DEBUG|TRACE)\b`),
+}
+
+// levelAliases maps level names to their normalized severity
+var levelAliases = map[string]string{
+	"fatal":    levelError,
+	"panic":    levelError,
+	"critical": levelError,
+	"crit":     levelError,
+	"error":    levelError,
+	"err":      levelError,
+	"warning":  levelWarn,
+	"warn":     levelWarn,
+	"notice":   levelInfo,
+	"info":     levelInfo,
+	"debug":    levelDebug,
+	"trace":    levelDebug,
+}
+
+// detectLevel finds the severity of a log line along with the position of its level token
+func detectLevel(line string) (string, int, int, bool) {
+	for _, re := range levelPatterns {
+		match := re.FindStringSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+		if level, ok := levelAliases[strings.ToLower(line[match[2]:match[3]])]; ok {
+			return level, match[2], match[3], true
+		}
+	}
+	return "", 0, 0, false
+}
+
+// validateLevelColors checks that mode is a supported --level-colors value
+func validateLevelColors(mode string) error {
+	switch mode {
+	case levelColorsToken, levelColorsLine, levelColorsOff:
+		return nil
+	}
+	return fmt.Errorf("unknown level color mode %q (expected token, line or off)", mode)
+}
+
+// highlighter colors the keywords and the severity level of log lines
+type highlighter struct {
+	patterns []*regexp.Regexp
+	levels   string
+}
+
+// colorSpan is a colored range of a line
+type colorSpan struct {
+	start, end int
+	color      string
+}
+
+// render returns the line with keyword matches highlighted and its level colored,
+// either the level token alone or the whole line depending on the mode. The level
+// comes from the parsed fields when known and is otherwise detected in the text.
+func (h *highlighter) render(line, level string) string {
+	if noColor {
+		return line
+	}
+
+	var spans []colorSpan
+	for _, re := range h.patterns {
+		for _, match := range re.FindAllStringIndex(line, -1) {
+			if match[1] > match[0] {
+				spans = append(spans, colorSpan{match[0], match[1], scheme.Highlight})
+			}
+		}
+	}
+
+	base := ""
+	if h.levels != levelColorsOff {
+		detected, start, end, found := detectLevel(line)
+		if level == "" {
+			level = detected
+		}
+		color := scheme.levelColor(level)
+		if h.levels == levelColorsLine {
+			base = color
+		} else if found && !overlapsSpans(spans, start, end) {
+			spans = append(spans, colorSpan{start, end, color})
+		}
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	last := 0
+	for _, span := range spans {
+		if span.start < last {
+			// Overlapping matches are merged into the previous highlight
+			span.start = last
+		}
+		if span.end <= span.start {
+			continue
+		}
+		b.WriteString(colorize(line[last:span.start], base))
+		b.WriteString(colorize(line[span.start:span.end], span.color))
+		last = span.end
+	}
+	b.WriteString(colorize(line[last:], base))
+	return b.String()
+}
+
+// overlapsSpans reports whether the range [start, end) overlaps any of the spans
+func overlapsSpans(spans []colorSpan, start, end int) bool {
+	for _, span := range spans {
+		if start < span.end && span.start < end {
+			return true
+		}
+	}
+	return false
+}
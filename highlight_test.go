@@ -0,0 +1,85 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestDetectLevel(t *testing.T) {
+	tests := []struct {
+		line  string
+		level string
+		token string
+	}{
+		{"2024/03/01 ERROR connection refused", levelError, "ERROR"},
+		{"WARNING: disk almost full", levelWarn, "WARNING"},
+		{`{"level":"error","msg":"boom"}`, levelError, "error"},
+		{`{"severity": "INFO", "msg": "ok"}`, levelInfo, "INFO"},
+		{"ts=2024-03-01 lvl=warn msg=slow", levelWarn, "warn"},
+		{`level="debug" msg="cache miss"`, levelDebug, "debug"},
+		{"FATAL out of memory", levelError, "FATAL"},
+		{"an error occurred in info handler", "", ""},
+		{"INFORMATION only", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			level, start, end, ok := detectLevel(tt.line)
+			if tt.level == "" {
+				if ok {
+					t.Errorf("detectLevel(%q) = %q, want no level", tt.line, level)
+				}
+				return
+			}
+			if !ok || level != tt.level || tt.line[start:end] != tt.token {
+				t.Errorf("detectLevel(%q) = %q, %q, %v, want %q, %q", tt.line, level, tt.line[start:end], ok, tt.level, tt.token)
+			}
+		})
+	}
+}
+
+func TestHighlighterRender(t *testing.T) {
+	defer func() { scheme = colorSchemes["default"] }()
+	scheme = colorScheme{Highlight: "<h>", Error: "<e>", Info: "<i>"}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		levels   string
+		line     string
+		level    string
+		want     string
+	}{
+		{"keywords", []string{"ERR", "fail"}, levelColorsOff, "ERR: request failed", "", "<h>ERR\033[0m: request <h>fail\033[0med"},
+		{"level token", nil, levelColorsToken, "ERROR boom", "", "<e>ERROR\033[0m boom"},
+		{"whole line", []string{"boom"}, levelColorsLine, "ERROR boom!", "", "<e>ERROR \033[0m<h>boom\033[0m<e>!\033[0m"},
+		{"keyword wins over level token", []string{"ERROR"}, levelColorsToken, "ERROR boom", "", "<h>ERROR\033[0m boom"},
+		{"overlapping keywords", []string{"time", "timeout"}, levelColorsOff, "timeout", "", "<h>time\033[0m<h>out\033[0m"},
+		{"no match", nil, levelColorsToken, "plain", "", "plain"},
+		{"parsed level colors the line", nil, levelColorsLine, `GET /api 200`, levelInfo, "<i>GET /api 200\033[0m"},
+		{"parsed level wins over the text", nil, levelColorsToken, "INFO retry failed", levelError, "<e>INFO\033[0m retry failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regexes, err := compilePatterns(tt.patterns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := &highlighter{patterns: regexes, levels: tt.levels}
+			if got := h.render(tt.line, tt.level); got != tt.want {
+				t.Errorf("render(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlighterRenderWithoutColor(t *testing.T) {
+	noColor = true
+	defer func() { noColor = false }()
+
+	h := &highlighter{patterns: []*regexp.Regexp{regexp.MustCompile("boom")}, levels: levelColorsLine}
+	if got := h.render("ERROR boom", ""); got != "ERROR boom" {
+		t.Errorf("render() = %q, want the plain line", got)
+	}
+}
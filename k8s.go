@@ -6,23 +6,97 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
+// createK8sClients creates one Kubernetes client per requested kubeconfig context
+func createK8sClients() ([]ClusterClient, error) {
+	contexts := splitCommaList(kubeContext)
+	if len(contexts) <= 1 {
+		// A single context may still carry stray spaces or commas, as in "staging,"
+		contextName := ""
+		if len(contexts) == 1 {
+			contextName = contexts[0]
+		}
+		cluster, err := createK8sClient(contextName)
+		if err != nil {
+			return nil, err
+		}
+		return []ClusterClient{cluster}, nil
+	}
+
+	var clusters []ClusterClient
+	for _, contextName := range contexts {
+		cluster, err := createK8sClient(contextName)
+		if err != nil {
+			return nil, fmt.Errorf("context %s: %v", contextName, err)
+		}
+		clusters = append(clusters, cluster)
+	}
+	// The clusters are known up front, so each one can get a color of its own
+	assignClusterColors(clusters)
+	return clusters, nil
+}
+
 // createK8sClient creates a Kubernetes client using in-cluster config or kubeconfig
-func createK8sClient() (*kubernetes.Clientset, error) {
-	// Try to use in-cluster config first
-	config, err := rest.InClusterConfig()
+func createK8sClient(contextName string) (ClusterClient, error) {
+	// Try to use in-cluster config first, unless kubeconfig options were given explicitly
+	if !hasKubeconfigOverrides() {
+		if config, err := rest.InClusterConfig(); err == nil {
+			config.Impersonate = rest.ImpersonationConfig{
+				UserName: impersonateUser,
+				Groups:   impersonateGroups,
+			}
+			clientset, err := newClientset(config)
+			return ClusterClient{Name: "in-cluster", Clientset: clientset}, err
+		}
+	}
+
+	// Fall back to kubeconfig, honouring $KUBECONFIG and the standard loading rules
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+
+	rawConfig, err := loadingRules.Load()
 	if err != nil {
-		// Fall back to kubeconfig
-		config, err = clientcmd.BuildConfigFromFlags("", clientcmd.RecommendedHomeFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create kubeconfig: %v", err)
+		return ClusterClient{}, fmt.Errorf("failed to load kubeconfig: %v", err)
+	}
+	if contextName == "" {
+		contextName = rawConfig.CurrentContext
+		if isInteractive() && len(rawConfig.Contexts) > 1 {
+			contextName, err = selectContext(rawConfig)
+			if err != nil {
+				return ClusterClient{}, err
+			}
 		}
 	}
 
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: contextName,
+		Context: clientcmdapi.Context{
+			Cluster:  kubeCluster,
+			AuthInfo: kubeUser,
+		},
+		AuthInfo: clientcmdapi.AuthInfo{
+			Impersonate:       impersonateUser,
+			ImpersonateGroups: impersonateGroups,
+		},
+	}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return ClusterClient{}, fmt.Errorf("failed to create kubeconfig: %v", err)
+	}
+
+	clientset, err := newClientset(config)
+	return ClusterClient{Name: contextName, Clientset: clientset}, err
+}
+
+// newClientset creates a clientset from a REST config
+func newClientset(config *rest.Config) (*kubernetes.Clientset, error) {
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create clientset: %v", err)
@@ -31,44 +105,170 @@ func createK8sClient() (*kubernetes.Clientset, error) {
 	return clientset, nil
 }
 
-// getAllPods retrieves all pod names in a given namespace
-func getAllPods(clientset *kubernetes.Clientset, namespace string) ([]string, error) {
-	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list pods: %v", err)
+// hasKubeconfigOverrides reports whether any kubeconfig selection flag was given
+func hasKubeconfigOverrides() bool {
+	return kubeconfig != "" || kubeContext != "" || kubeCluster != "" || kubeUser != ""
+}
+
+// validateSelector checks that a label selector is syntactically valid
+func validateSelector(selector string) error {
+	if selector == "" {
+		return nil
 	}
+	if _, err := labels.Parse(selector); err != nil {
+		return err
+	}
+	return nil
+}
+
+// listPods retrieves pods across the given namespaces, optionally filtered by a label selector
+func listPods(clientset *kubernetes.Clientset, namespaces []string, selector string) ([]corev1.Pod, error) {
+	var pods []corev1.Pod
+	for _, ns := range namespaces {
+		podList, err := clientset.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: selector,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods in %s: %v", namespaceLabel(ns), err)
+		}
 
-	var podNames []string
-	for _, pod := range pods.Items {
-		podNames = append(podNames, pod.Name)
+		// Check if there are any pods in the namespace
+		if len(podList.Items) == 0 {
+			printStatus("No pods found in %s, skipping...\n", namespaceLabel(ns))
+		}
+		pods = append(pods, podList.Items...)
 	}
+	return pods, nil
+}
 
-	// Check if there are any pods in the namespace
-	if len(podNames) == 0 {
-		fmt.Printf("No pods found in namespace %s, skipping...\n", namespace)
+// getAllPods retrieves all pods in the given namespaces, optionally filtered by a label selector
+func getAllPods(clientset *kubernetes.Clientset, namespaces []string, selector string) ([]PodInfo, error) {
+	pods, err := listPods(clientset, namespaces, selector)
+	if err != nil {
+		return nil, err
 	}
 
-	return podNames, nil
+	var podInfos []PodInfo
+	for i := range pods {
+		podInfos = append(podInfos, expandPodContainers(&pods[i])...)
+	}
+
+	return podInfos, nil
 }
 
-// getFirstContainer retrieves the first container name from a pod
-func getFirstContainer(clientset *kubernetes.Clientset, namespace, podName string) (string, error) {
+// getPodContainers retrieves a single pod and expands it into one entry per selected container
+func getPodContainers(clientset *kubernetes.Clientset, namespace, podName string) ([]PodInfo, error) {
 	pod, err := clientset.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
 	if err != nil {
-		return "", fmt.Errorf("failed to get pod: %v", err)
+		return nil, fmt.Errorf("failed to get pod: %v", err)
 	}
 
-	if len(pod.Spec.Containers) == 0 {
-		return "", fmt.Errorf("no containers found in pod")
+	podInfos := expandPodContainers(pod)
+	if len(podInfos) == 0 {
+		return nil, fmt.Errorf("no matching containers found in pod")
 	}
 
-	return pod.Spec.Containers[0].Name, nil
+	return podInfos, nil
+}
+
+// expandPodContainers returns one PodInfo per container selected from a pod
+func expandPodContainers(pod *corev1.Pod) []PodInfo {
+	var podInfos []PodInfo
+	for _, name := range getContainerNames(pod) {
+		podInfos = append(podInfos, PodInfo{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			Container: name,
+			Node:      pod.Spec.NodeName,
+		})
+	}
+	return podInfos
 }
 
-// getContainerName extracts the container name from a pod object
-func getContainerName(pod *corev1.Pod) string {
-	if len(pod.Spec.Containers) == 0 {
-		return ""
+// getContainerNames returns the names of the containers to stream from a pod.
+// An explicit container name wins; otherwise init containers are included with
+// --init-containers, every container with --all-containers or a container regex,
+// and only the first container by default.
+func getContainerNames(pod *corev1.Pod) []string {
+	if container != "" {
+		return []string{container}
+	}
+
+	var candidates []string
+	if initContainers {
+		for _, c := range pod.Spec.InitContainers {
+			candidates = append(candidates, c.Name)
+		}
+	}
+	if allContainers || containerRegex != nil {
+		for _, c := range pod.Spec.Containers {
+			candidates = append(candidates, c.Name)
+		}
+	} else if len(pod.Spec.Containers) > 0 {
+		candidates = append(candidates, pod.Spec.Containers[0].Name)
+	}
+
+	if containerRegex == nil {
+		return candidates
+	}
+
+	var names []string
+	for _, name := range candidates {
+		if containerRegex.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isInitContainer reports whether the named container is an init container of the pod
+func isInitContainer(pod *corev1.Pod, name string) bool {
+	for _, c := range pod.Spec.InitContainers {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getContainerStatus returns the status of the named container, looking at init containers too
+func getContainerStatus(pod *corev1.Pod, name string) *corev1.ContainerStatus {
+	for i := range pod.Status.InitContainerStatuses {
+		if pod.Status.InitContainerStatuses[i].Name == name {
+			return &pod.Status.InitContainerStatuses[i]
+		}
+	}
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == name {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// describeTermination summarizes why a container instance terminated, e.g. "OOMKilled, exit code 137"
+func describeTermination(terminated *corev1.ContainerStateTerminated) string {
+	reason := terminated.Reason
+	if reason == "" {
+		reason = "Terminated"
+	}
+	description := fmt.Sprintf("%s, exit code %d", reason, terminated.ExitCode)
+	if terminated.Signal != 0 {
+		description += fmt.Sprintf(", signal %d", terminated.Signal)
+	}
+	return description
+}
+
+// isContainerReady reports whether a container has logs available to stream.
+// Regular containers must be ready in a running pod; init containers only need
+// to have started, since they run before the pod reaches the Running phase.
+func isContainerReady(pod *corev1.Pod, name string) bool {
+	status := getContainerStatus(pod, name)
+	if status == nil {
+		return false
+	}
+	if isInitContainer(pod, name) {
+		return status.State.Running != nil || status.State.Terminated != nil
 	}
-	return pod.Spec.Containers[0].Name
+	return pod.Status.Phase == corev1.PodRunning && status.Ready
 }
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newTestPod() *corev1.Pod {
+	return &corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init-db"}},
+			Containers:     []corev1.Container{{Name: "app"}, {Name: "istio-proxy"}, {Name: "log-shipper"}},
+		},
+	}
+}
+
+func TestGetContainerNames(t *testing.T) {
+	tests := []struct {
+		name           string
+		container      string
+		allContainers  bool
+		initContainers bool
+		pattern        string
+		expected       []string
+	}{
+		{
+			name:     "Default picks first container",
+			expected: []string{"app"},
+		},
+		{
+			name:      "Explicit container wins",
+			container: "istio-proxy",
+			pattern:   "^log",
+			expected:  []string{"istio-proxy"},
+		},
+		{
+			name:          "All containers",
+			allContainers: true,
+			expected:      []string{"app", "istio-proxy", "log-shipper"},
+		},
+		{
+			name:           "Init containers with first container",
+			initContainers: true,
+			expected:       []string{"init-db", "app"},
+		},
+		{
+			name:           "All and init containers",
+			allContainers:  true,
+			initContainers: true,
+			expected:       []string{"init-db", "app", "istio-proxy", "log-shipper"},
+		},
+		{
+			name:     "Regex filters all containers",
+			pattern:  "^(app|log-.*)$",
+			expected: []string{"app", "log-shipper"},
+		},
+		{
+			name:           "Regex applies to init containers",
+			initContainers: true,
+			pattern:        "^init-",
+			expected:       []string{"init-db"},
+		},
+		{
+			name:     "Regex without match",
+			pattern:  "^nothing$",
+			expected: nil,
+		},
+	}
+
+	defer func() {
+		container, allContainers, initContainers, containerRegex = "", false, false, nil
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container = tt.container
+			allContainers = tt.allContainers
+			initContainers = tt.initContainers
+			containerRegex = nil
+			if tt.pattern != "" {
+				containerRegex = regexp.MustCompile(tt.pattern)
+			}
+
+			actual := getContainerNames(newTestPod())
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("getContainerNames() = %v, want %v", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsContainerReady(t *testing.T) {
+	pod := newTestPod()
+	pod.Status = corev1.PodStatus{
+		Phase: corev1.PodRunning,
+		InitContainerStatuses: []corev1.ContainerStatus{
+			{Name: "init-db", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+		},
+		ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "app", Ready: true},
+			{Name: "istio-proxy", Ready: false},
+		},
+	}
+
+	tests := []struct {
+		container string
+		expected  bool
+	}{
+		{container: "init-db", expected: true},
+		{container: "app", expected: true},
+		{container: "istio-proxy", expected: false},
+		{container: "log-shipper", expected: false},
+	}
+
+	for _, tt := range tests {
+		if got := isContainerReady(pod, tt.container); got != tt.expected {
+			t.Errorf("isContainerReady(%q) = %v, want %v", tt.container, got, tt.expected)
+		}
+	}
+
+	pod.Status.Phase = corev1.PodPending
+	if isContainerReady(pod, "app") {
+		t.Errorf("isContainerReady(%q) = true for pending pod, want false", "app")
+	}
+}
+
+func TestDescribeTermination(t *testing.T) {
+	tests := []struct {
+		name       string
+		terminated corev1.ContainerStateTerminated
+		expected   string
+	}{
+		{
+			name:       "OOMKilled",
+			terminated: corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+			expected:   "OOMKilled, exit code 137",
+		},
+		{
+			name:       "Error with signal",
+			terminated: corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 143, Signal: 15},
+			expected:   "Error, exit code 143, signal 15",
+		},
+		{
+			name:       "Missing reason",
+			terminated: corev1.ContainerStateTerminated{ExitCode: 1},
+			expected:   "Terminated, exit code 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeTermination(&tt.terminated); got != tt.expected {
+				t.Errorf("describeTermination() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCreateK8sClientsContextList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	config := `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster: {server: "https://dev.example:6443"}
+- name: staging
+  cluster: {server: "https://staging.example:6443"}
+users:
+- name: admin
+  user: {token: secret}
+contexts:
+- name: dev
+  context: {cluster: dev, user: admin}
+- name: staging
+  context: {cluster: staging, user: admin}
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { kubeconfig, kubeContext = "", "" }()
+	kubeconfig = path
+
+	tests := []struct {
+		context  string
+		expected []string
+	}{
+		{context: "staging", expected: []string{"staging"}},
+		{context: "staging,", expected: []string{"staging"}},
+		{context: " staging", expected: []string{"staging"}},
+		{context: "dev, staging", expected: []string{"dev", "staging"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.context, func(t *testing.T) {
+			kubeContext = tt.context
+			clusters, err := createK8sClients()
+			if err != nil {
+				t.Fatalf("createK8sClients() error: %v", err)
+			}
+			var names []string
+			for _, cluster := range clusters {
+				names = append(names, cluster.Name)
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("createK8sClients() contexts = %v, want %v", names, tt.expected)
+			}
+		})
+	}
+}
@@ -3,8 +3,13 @@ package main
 import (
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
 )
 
 var (
@@ -15,10 +20,73 @@ var (
 	container   string
 	noColor     bool
 	watch       bool
+	selector    string
+
+	allNamespaces    bool
+	allContainers    bool
+	initContainers   bool
+	containerPattern string
+	containerRegex   *regexp.Regexp
+
+	kubeconfig        string
+	kubeContext       string
+	kubeCluster       string
+	kubeUser          string
+	impersonateUser   string
+	impersonateGroups []string
+
+	showPrevious bool
+
+	sinceDuration time.Duration
+	sinceTimeArg  string
+	untilArg      string
+	sinceTime     time.Time
+	untilTime     time.Time
+
+	noFollow   bool
+	sortLogs   bool
+	sortWindow time.Duration
+
+	showTimestamps bool
+	timeFormat     string
+	timeZone       string
+	timeLocation   *time.Location
+
+	includePatterns []string
+	excludePatterns []string
+	includeRegexes  []*regexp.Regexp
+	excludeRegexes  []*regexp.Regexp
+	contextBefore   int
+	contextAfter    int
+
+	highlightPatterns []string
+	highlightRegexes  []*regexp.Regexp
+	levelColors       string
+	colorSchemeName   string
+	colorOverrides    string
+
+	outputFormat string
+	fieldList    string
+	jsonExpand   bool
+
+	parserList     string
+	enabledParsers []lineParser
+
+	whereQuery  string
+	whereFilter queryNode
+	outputMode  string
+
+	templateText string
+	lineTemplate *template.Template
+
+	outputDir      string
+	rotateSize     int
+	rotateInterval time.Duration
+	gzipRotated    bool
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "ktail",
+	Use:   "ktail [TYPE/NAME ...]",
 	Short: "A Kubernetes log tail utility with interactive namespace and pod selection",
 	Long: `ktail is a tool that provides tail-like functionality for Kubernetes pod logs.
 It allows you to interactively select namespaces and pods using fzf for a better user experience.
@@ -34,21 +102,87 @@ Examples:
   ktail                                    # Interactive selection (all pods)
   ktail -n my-namespace                    # All pods in my-namespace
   ktail -n my-namespace -w                 # All pods in my-namespace with watch mode
+  ktail -n gateway,app,worker              # Pods across several namespaces
+  ktail -A -l app=checkout -w              # Matching pods in all namespaces with watch mode
   ktail -m                                 # Multi-select pods
   ktail -n my-ns -p my-pod                 # Specific pod
+  ktail -n my-ns -l app=api,tier!=canary   # Pods matching a label selector
+  ktail -n my-ns --all-containers          # Every container, including sidecars
+  ktail -n my-ns deploy/checkout -w        # Pods of a deployment, following rollouts
+  ktail -n my-ns sts/kafka cj/cleanup      # Pods of several workloads
+  ktail --context staging -n my-ns         # Use a specific kubeconfig context
+  ktail --context eu,us,ap -n my-ns -w     # Same namespace across several clusters
+  ktail -n my-ns --since 15m               # Logs of the last 15 minutes and follow
+  ktail -n my-ns --since-time 09:00 --until 09:05  # Logs of an incident window
+  ktail -n my-ns -l app=api --no-follow    # Dump recent logs and exit
+  ktail -n my-ns -l app=api --sort         # Interleave pods in chronological order
+  ktail -n my-ns --time-format short --tz UTC  # Prefix each line with its UTC time
+  ktail -n my-ns -i 'ERROR|WARN' -e healthz -B 2  # Matching lines with 2 lines of context
+  ktail -n my-ns --highlight 'timeout,5\d\d' --level-colors line  # Highlight keywords, color lines by level
+  ktail -n my-ns --format pretty --fields time,level,msg,trace_id  # Compact view of JSON logs
+  ktail -n my-ns --where 'level>=warn && status>=500'  # Filter on parsed fields
+  ktail -n my-ns -o json | jq .message       # JSON Lines for other tools
+  ktail -n my-ns --template '{{.Time}} {{color .Pod}} {{.Field "trace_id"}} {{.Message}}'  # Custom line layout
+  ktail -n my-ns -w --output-dir ./incident --rotate-size 100 --gzip  # Save raw logs per container while tailing
   ktail -1000f                             # Follow recent 1000 lines
   ktail -500f -n my-ns                     # Follow recent 500 lines from my-ns namespace`,
-	Run: runKtail,
+	Args: cobra.ArbitraryArgs,
+	Run:  runKtail,
 }
 
 func init() {
-	rootCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "Kubernetes namespace (if not provided, will be selected interactively)")
+	rootCmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "", "Kubernetes namespace(s), comma separated (if not provided, will be selected interactively)")
+	rootCmd.PersistentFlags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "Tail pods across all namespaces")
 	rootCmd.Flags().StringVarP(&podName, "pod", "p", "", "Pod name (if not provided, will select all pods in namespace)")
-	rootCmd.Flags().IntVarP(&tailLines, "tail", "t", 10, "Number of lines to show from the end of logs")
+	rootCmd.PersistentFlags().IntVarP(&tailLines, "tail", "t", 10, "Number of lines to show from the end of logs")
+	rootCmd.PersistentFlags().DurationVar(&sinceDuration, "since", 0, "Only show logs newer than a relative duration like 15m or 2h")
+	rootCmd.PersistentFlags().StringVar(&sinceTimeArg, "since-time", "", "Only show logs after a time (RFC3339, 09:00 or relative like 15m ago)")
+	rootCmd.PersistentFlags().StringVar(&untilArg, "until", "", "Stop each stream once its logs pass a time (RFC3339, 09:05 or relative like 5m ago)")
+	rootCmd.Flags().BoolVar(&noFollow, "no-follow", false, "Print the available logs and exit instead of following them")
+	rootCmd.Flags().BoolVar(&sortLogs, "sort", false, "Merge lines from all pods in timestamp order")
+	rootCmd.Flags().DurationVar(&sortWindow, "sort-window", 2*time.Second, "How long lines are buffered for --sort before being printed")
+	rootCmd.Flags().StringVar(&outputDir, "output-dir", "", "Also save each container's logs to <dir>/<namespace>/<pod>/<container>.log")
+	rootCmd.Flags().IntVar(&rotateSize, "rotate-size", 0, "Rotate saved log files once they reach this many MiB (0 disables)")
+	rootCmd.Flags().DurationVar(&rotateInterval, "rotate-interval", 0, "Rotate saved log files after this long, e.g. 1h (0 disables)")
+	rootCmd.Flags().BoolVar(&gzipRotated, "gzip", false, "Compress rotated log files with gzip")
 	rootCmd.Flags().BoolVarP(&multiSelect, "multi", "m", true, "Enable multi-selection for pods")
-	rootCmd.Flags().StringVarP(&container, "container", "c", "", "Container name")
+	rootCmd.PersistentFlags().StringVarP(&container, "container", "c", "", "Container name")
+	rootCmd.PersistentFlags().BoolVar(&allContainers, "all-containers", false, "Stream every container in each pod")
+	rootCmd.PersistentFlags().BoolVar(&initContainers, "init-containers", false, "Include init containers")
+	rootCmd.PersistentFlags().StringVar(&containerPattern, "container-regex", "", "Stream only containers whose name matches this regex")
 	rootCmd.Flags().BoolVarP(&watch, "watch", "w", false, "Watch mode : works when namespace only selected.")
-	rootCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	rootCmd.Flags().BoolVar(&showPrevious, "show-previous", false, "Dump the logs of the crashed instance when a container restarts (watch mode)")
+	rootCmd.PersistentFlags().StringVarP(&selector, "selector", "l", "", "Label selector to filter pods (e.g. app=api,tier!=canary)")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	rootCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file (defaults to $KUBECONFIG or ~/.kube/config)")
+	rootCmd.PersistentFlags().StringVar(&kubeContext, "context", "", "Kubeconfig context(s) to use, comma separated (if not provided, will be selected interactively when several exist)")
+	rootCmd.PersistentFlags().StringVar(&kubeCluster, "cluster", "", "Kubeconfig cluster to use")
+	rootCmd.PersistentFlags().StringVar(&kubeUser, "user", "", "Kubeconfig user to use")
+	rootCmd.PersistentFlags().StringVar(&impersonateUser, "as", "", "Username to impersonate for the operation")
+	rootCmd.PersistentFlags().StringArrayVar(&impersonateGroups, "as-group", nil, "Group to impersonate for the operation, can be repeated")
+	addOutputFlags(rootCmd)
+}
+
+// addOutputFlags registers the flags that filter and format printed lines, shared by ktail and ktail replay
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&showTimestamps, "timestamps", false, "Show when each line was logged")
+	cmd.Flags().StringVar(&timeFormat, "time-format", timeFormatRFC3339, "Timestamp format: rfc3339, short, relative or unix (implies --timestamps)")
+	cmd.Flags().StringVar(&timeZone, "tz", "local", "Time zone timestamps are shown in, e.g. UTC or Asia/Seoul (implies --timestamps)")
+	cmd.Flags().StringArrayVarP(&includePatterns, "include", "i", nil, "Only show lines matching this regex, can be repeated")
+	cmd.Flags().StringArrayVarP(&excludePatterns, "exclude", "e", nil, "Hide lines matching this regex, can be repeated")
+	cmd.Flags().IntVarP(&contextBefore, "before-context", "B", 0, "Lines of context to show before each --include match")
+	cmd.Flags().IntVar(&contextAfter, "after-context", 0, "Lines of context to show after each --include match")
+	cmd.Flags().StringVar(&outputFormat, "format", formatRaw, "How parsed log lines are shown: raw or pretty (time LEVEL msg key=value)")
+	cmd.Flags().StringVar(&fieldList, "fields", "", "Only show these comma separated fields of parsed log lines")
+	cmd.Flags().BoolVar(&jsonExpand, "json-expand", false, "Spread parsed log lines over indented lines")
+	cmd.Flags().StringVar(&parserList, "parsers", strings.Join(parserNames(), ","), "Log formats to parse into fields, comma separated, or none")
+	cmd.Flags().StringVar(&whereQuery, "where", "", `Only show lines whose fields match a query, e.g. 'level>=warn && status>=500 && path=~"^/api"'`)
+	cmd.Flags().StringVarP(&outputMode, "output", "o", outputText, "Output mode: text or json (one JSON object per line)")
+	cmd.Flags().StringVar(&templateText, "template", "", `Go template for each line, e.g. '{{.Time}} {{.Pod | pad 30}} {{color .Level}} {{.Message}}'`)
+	cmd.Flags().StringArrayVar(&highlightPatterns, "highlight", nil, "Highlight matches of these comma separated regexes, can be repeated")
+	cmd.Flags().StringVar(&levelColors, "level-colors", levelColorsToken, "Color lines by detected log level: token, line or off")
+	cmd.Flags().StringVar(&colorSchemeName, "color-scheme", "default", "Color scheme: default, bright or subtle")
+	cmd.Flags().StringVar(&colorOverrides, "colors", "", "Override scheme colors, e.g. error=bold+red,pod=208,timestamp=#808080")
 }
 
 func main() {
@@ -62,89 +196,323 @@ func main() {
 }
 
 func runKtail(cmd *cobra.Command, args []string) {
-	// Create Kubernetes client
-	clientset, err := createK8sClient()
+	// Validate the output flags first, status messages depend on the output mode
+	parseOutputFlags(cmd)
+
+	// Create Kubernetes clients, one per requested context
+	clusters, err := createK8sClients()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create Kubernetes client: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Determine target namespaces
-	var targetNamespace string
-	// Single namespace selection
-	targetNamespace, err = selectNamespace(clientset)
+	if rotateSize < 0 || rotateInterval < 0 {
+		fmt.Fprintf(os.Stderr, "Rotation limits cannot be negative\n")
+		os.Exit(1)
+	}
+	if outputDir == "" && (rotateSize > 0 || rotateInterval > 0 || gzipRotated) {
+		fmt.Fprintf(os.Stderr, "--rotate-size, --rotate-interval and --gzip require --output-dir\n")
+		os.Exit(1)
+	}
+
+	// Validate the selector, container regex and time window before touching the cluster
+	parseSelectionFlags(cmd)
+
+	if noFollow && watch {
+		fmt.Fprintf(os.Stderr, "--watch cannot be combined with --no-follow\n")
+		os.Exit(1)
+	}
+	// A watched pod would stream the closed window again each time it restarts
+	if untilArg != "" && watch {
+		fmt.Fprintf(os.Stderr, "--watch cannot be combined with --until\n")
+		os.Exit(1)
+	}
+
+	if podName != "" && len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "A pod name cannot be combined with workloads\n")
+		os.Exit(1)
+	}
+
+	// Determine target namespaces, selecting interactively against the first cluster
+	targetNamespaces := resolveTargetNamespaces(clusters)
+	if podName != "" && (len(targetNamespaces) != 1 || targetNamespaces[0] == "") {
+		fmt.Fprintf(os.Stderr, "A single namespace is required when a pod name is given\n")
+		os.Exit(1)
+	}
+
+	// Collect pods from all target namespaces in every cluster
+	allPods, watchTargets := collectClusterPods(clusters, targetNamespaces, args)
+
+	// In watch mode we can start empty and pick up pods as they appear
+	watchMode := watch && (podName == "")
+	if len(allPods) == 0 && !watchMode {
+		fmt.Fprintf(os.Stderr, "No pods selected\n")
+		os.Exit(1)
+	}
+
+	// Display selected pods
+	if len(clusters) > 1 {
+		printStatus("Tailing logs for %d pod(s) across %d namespace(s) in %d cluster(s)\n", len(allPods), countNamespaces(allPods), len(clusters))
+	} else {
+		printStatus("Tailing logs for %d pod(s) across %d namespace(s)\n", len(allPods), countNamespaces(allPods))
+	}
+	for _, pod := range allPods {
+		printStatus("  - %s (container: %s)\n",
+			formatPodName(pod, len(clusters) > 1),
+			colorizeContainer(pod.Container))
+	}
+	if followLogs() && !untilTime.IsZero() {
+		printStatus("Following logs until %s, press Ctrl+C to stop earlier...\n", untilTime.Format(time.RFC3339))
+	} else if followLogs() {
+		printStatus("Press Ctrl+C to stop...\n")
+	}
+
+	err = streamLogsWithWatch(clusters, allPods, watchTargets, watchMode)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to select namespace: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to stream logs: %v\n", err)
 		os.Exit(1)
 	}
+}
 
-	// Collect pods from all target namespaces
-	var allPods []PodInfo
+// parseOutputFlags validates the flags registered by addOutputFlags and compiles their
+// patterns, query and template, exiting on invalid values like the run functions
+func parseOutputFlags(cmd *cobra.Command) {
+	if err := validateOutputMode(outputMode); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid output mode: %v\n", err)
+		os.Exit(1)
+	}
+	if outputMode == outputJSON {
+		noColor = true
+	}
+
+	var err error
+	includeRegexes, err = compilePatterns(includePatterns)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid include regex: %v\n", err)
+		os.Exit(1)
+	}
+	excludeRegexes, err = compilePatterns(excludePatterns)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid exclude regex: %v\n", err)
+		os.Exit(1)
+	}
+	var highlightList []string
+	for _, value := range highlightPatterns {
+		highlightList = append(highlightList, splitCommaList(value)...)
+	}
+	highlightRegexes, err = compilePatterns(highlightList)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid highlight regex: %v\n", err)
+		os.Exit(1)
+	}
+	if err := validateLevelColors(levelColors); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid level colors: %v\n", err)
+		os.Exit(1)
+	}
+	scheme, err = loadColorScheme(colorSchemeName, colorOverrides)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid color scheme: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Original logic for single namespace or when not using multi-select across namespaces
+	if err := validateFormat(outputFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid format: %v\n", err)
+		os.Exit(1)
+	}
 
-	var podNames []string
-	if podName != "" {
-		// Single pod specified
-		podNames = []string{podName}
-	} else if multiSelect {
-		// Multi-select pods in single namespace
-		podNames, err = selectPodsMulti(clientset, targetNamespace)
+	enabledParsers, err = selectParsers(splitCommaList(parserList))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid parsers: %v\n", err)
+		os.Exit(1)
+	}
+
+	if whereQuery != "" {
+		whereFilter, err = parseQuery(whereQuery)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to select pods in namespace %s: %v\n", targetNamespace, err)
+			fmt.Fprintf(os.Stderr, "Invalid --where query: %v\n", err)
 			os.Exit(1)
 		}
-	} else {
-		// Default: Select all pods in the namespace
-		podNames, err = getAllPods(clientset, targetNamespace)
+	}
+
+	if templateText != "" {
+		if outputMode == outputJSON {
+			fmt.Fprintf(os.Stderr, "--template cannot be combined with --output json\n")
+			os.Exit(1)
+		}
+		lineTemplate, err = parseLineTemplate(templateText)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to get all pods in namespace %s: %v\n", targetNamespace, err)
+			fmt.Fprintf(os.Stderr, "Invalid template: %v\n", err)
 			os.Exit(1)
 		}
 	}
 
-	// Skip if no pods found in this namespace
-	if len(podNames) == 0 {
-		return
+	if contextBefore < 0 || contextAfter < 0 {
+		fmt.Fprintf(os.Stderr, "Context line counts cannot be negative\n")
+		os.Exit(1)
 	}
 
-	// Get container names for all selected pods in this namespace
-	for _, pod := range podNames {
-		var containerName string
-		if container == "" {
-			containerName, err = getFirstContainer(clientset, targetNamespace, pod)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to get container name for pod %s in namespace %s: %v\n", pod, targetNamespace, err)
-				os.Exit(1)
-			}
-		} else {
-			containerName = container
-		}
-		allPods = append(allPods, PodInfo{
-			Namespace: targetNamespace,
-			Name:      pod,
-			Container: containerName,
-		})
+	if err := parseTimestampFlags(cmd); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid timestamp options: %v\n", err)
+		os.Exit(1)
 	}
+}
 
-	if len(allPods) == 0 {
-		fmt.Fprintf(os.Stderr, "No pods selected\n")
+// parseSelectionFlags validates the label selector, compiles the container regex and
+// parses the time window shared by every command, exiting on invalid values
+func parseSelectionFlags(cmd *cobra.Command) {
+	if err := validateSelector(selector); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid label selector: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Display selected pods
-	fmt.Printf("Tailing logs for %d pod(s) across %d namespace(s)\n", len(allPods), len(targetNamespace))
-	for _, pod := range allPods {
-		fmt.Printf("  - %s/%s (container: %s)\n",
-			colorizeNamespace(pod.Namespace),
-			colorizePod(pod.Name),
-			colorizeContainer(pod.Container))
+	if containerPattern != "" {
+		var err error
+		containerRegex, err = regexp.Compile(containerPattern)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid container regex: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := parseTimeWindow(cmd); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid time window: %v\n", err)
+		os.Exit(1)
 	}
-	fmt.Println("Press Ctrl+C to stop...")
+}
 
-	err = streamLogsWithWatch(clientset, allPods, targetNamespace, watch && (podName == ""))
+// resolveTargetNamespaces determines the namespaces to read from, selecting
+// interactively against the first cluster, and exits when none are selected
+func resolveTargetNamespaces(clusters []ClusterClient) []string {
+	targetNamespaces, err := resolveNamespaces(clusters[0].Clientset)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to stream logs: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to select namespace: %v\n", err)
+		os.Exit(1)
+	}
+	if len(targetNamespaces) == 0 {
+		fmt.Fprintf(os.Stderr, "No namespaces selected\n")
 		os.Exit(1)
 	}
+	return targetNamespaces
+}
+
+// collectClusterPods collects the pods and watch targets from the given namespaces
+// in every cluster, tagged with their cluster's name
+func collectClusterPods(clusters []ClusterClient, namespaces []string, workloads []string) ([]PodInfo, []WatchTarget) {
+	var allPods []PodInfo
+	var watchTargets []WatchTarget
+	for _, cluster := range clusters {
+		pods, targets, err := collectPods(cluster.Clientset, namespaces, workloads)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to collect pods from %s: %v\n", cluster.Name, err)
+			os.Exit(1)
+		}
+		for i := range pods {
+			pods[i].Cluster = cluster.Name
+		}
+		for i := range targets {
+			targets[i].Cluster = cluster.Name
+		}
+		allPods = append(allPods, pods...)
+		watchTargets = append(watchTargets, targets...)
+	}
+	return allPods, watchTargets
+}
+
+// parseTimeWindow validates the --since, --since-time and --until flags
+func parseTimeWindow(cmd *cobra.Command) error {
+	now := time.Now()
+
+	if sinceDuration > 0 && sinceTimeArg != "" {
+		return fmt.Errorf("--since and --since-time cannot be used together")
+	}
+	if sinceTimeArg != "" {
+		t, err := parseTimeArg(sinceTimeArg, now)
+		if err != nil {
+			return err
+		}
+		sinceTime = t
+	}
+	if untilArg != "" {
+		t, err := parseTimeArg(untilArg, now)
+		if err != nil {
+			return err
+		}
+		untilTime = t
+	}
+
+	start := sinceTime
+	if sinceDuration > 0 {
+		start = now.Add(-sinceDuration)
+	}
+	if !start.IsZero() && !untilTime.IsZero() && !untilTime.After(start) {
+		return fmt.Errorf("--until must be later than the start of the time window")
+	}
+
+	// A time window shows every line in it unless a tail limit is given explicitly
+	if !start.IsZero() && !cmd.Flags().Changed("tail") {
+		tailLines = -1
+	}
+
+	return nil
+}
+
+// parseTimestampFlags validates the --timestamps, --time-format and --tz flags
+func parseTimestampFlags(cmd *cobra.Command) error {
+	// Choosing a format or time zone only makes sense when timestamps are shown
+	if cmd.Flags().Changed("time-format") || cmd.Flags().Changed("tz") {
+		showTimestamps = true
+	}
+	if err := validateTimeFormat(timeFormat); err != nil {
+		return err
+	}
+
+	location, err := loadTimeZone(timeZone)
+	if err != nil {
+		return err
+	}
+	timeLocation = location
+	return nil
+}
+
+// collectPods resolves the pods to tail in one cluster along with the watch targets that discover new ones
+func collectPods(clientset *kubernetes.Clientset, namespaces []string, workloads []string) ([]PodInfo, []WatchTarget, error) {
+	var pods []PodInfo
+	var err error
+
+	if len(workloads) > 0 {
+		// Pods of the given workloads
+		return resolveWorkloadPods(clientset, namespaces, workloads)
+	} else if podName != "" {
+		// Single pod specified
+		pods, err = getPodContainers(clientset, namespaces[0], podName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get containers for pod %s in namespace %s: %v", podName, namespaces[0], err)
+		}
+	} else if selector != "" {
+		// Pods matching the label selector
+		pods, err = getAllPods(clientset, namespaces, selector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get pods matching %q: %v", selector, err)
+		}
+	} else if multiSelect {
+		// Multi-select pods across the target namespaces
+		pods, err = selectPodsMulti(clientset, namespaces)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to select pods: %v", err)
+		}
+	} else {
+		// Default: Select all pods in the target namespaces
+		pods, err = getAllPods(clientset, namespaces, "")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get all pods: %v", err)
+		}
+	}
+
+	// Watch every target namespace using the label selector
+	var targets []WatchTarget
+	for _, ns := range namespaces {
+		targets = append(targets, WatchTarget{Namespace: ns, Selector: selector})
+	}
+
+	return pods, targets, nil
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Supported --output modes
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// validateOutputMode checks that mode is a supported --output value
+func validateOutputMode(mode string) error {
+	switch mode {
+	case outputText, outputJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown output mode %q (expected text or json)", mode)
+}
+
+// jsonRecord is a log line as written by --output json
+type jsonRecord struct {
+	Cluster   string         `json:"cluster,omitempty"`
+	Namespace string         `json:"namespace"`
+	Pod       string         `json:"pod"`
+	Container string         `json:"container"`
+	Node      string         `json:"node,omitempty"`
+	Timestamp string         `json:"timestamp,omitempty"`
+	Message   string         `json:"message"`
+	Fields    map[string]any `json:"fields,omitempty"`
+}
+
+// newJSONRecord converts a log line into its JSON record
+func newJSONRecord(logLine LogLine) jsonRecord {
+	record := jsonRecord{
+		Cluster:   logLine.PodInfo.Cluster,
+		Namespace: logLine.PodInfo.Namespace,
+		Pod:       logLine.PodInfo.Name,
+		Container: logLine.PodInfo.Container,
+		Node:      logLine.PodInfo.Node,
+		Message:   logLine.Line,
+	}
+	if !logLine.Time.IsZero() {
+		record.Timestamp = logLine.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if logLine.Entry != nil {
+		record.Fields = logLine.Entry.Fields
+	}
+	return record
+}
+
+// printJSONLine writes a log line as one JSON object. ktail's own markers
+// are not log records and go to stderr as text instead.
+func printJSONLine(w io.Writer, logLine LogLine, showCluster, showContainer bool) {
+	if logLine.Time.IsZero() {
+		fmt.Fprintf(os.Stderr, "%s %s\n", formatPrefix(logLine.PodInfo, showCluster, showContainer), logLine.Line)
+		return
+	}
+
+	encoded, err := json.Marshal(newJSONRecord(logLine))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode log line from %s/%s: %v\n", logLine.PodInfo.Namespace, logLine.PodInfo.Name, err)
+		return
+	}
+	fmt.Fprintln(w, string(encoded))
+}
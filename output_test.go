@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewJSONRecord(t *testing.T) {
+	line := `{"level":"info","msg":"ok"}`
+	logLine := LogLine{
+		PodInfo: PodInfo{Cluster: "eu", Namespace: "shop", Name: "api-1", Container: "app", Node: "node-a"},
+		Time:    time.Date(2024, 3, 1, 9, 0, 0, 500, time.FixedZone("KST", 9*60*60)),
+		Line:    line,
+		Entry:   parseLogLine(line, lineParsers),
+	}
+
+	encoded, err := json.Marshal(newJSONRecord(logLine))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(encoded, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"cluster":   "eu",
+		"namespace": "shop",
+		"pod":       "api-1",
+		"container": "app",
+		"node":      "node-a",
+		"timestamp": "2024-03-01T00:00:00.0000005Z",
+		"message":   line,
+		"fields":    map[string]any{"level": "info", "msg": "ok"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("record = %v, want %v", got, want)
+	}
+}
+
+func TestNewJSONRecordOmitsEmptyFields(t *testing.T) {
+	record := newJSONRecord(LogLine{PodInfo: PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}, Time: time.Unix(0, 1), Line: "plain"})
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"namespace":"shop","pod":"api-1","container":"app","timestamp":"1970-01-01T00:00:00.000000001Z","message":"plain"}`
+	if string(encoded) != want {
+		t.Errorf("record = %s, want %s", encoded, want)
+	}
+}
+
+func TestValidateOutputMode(t *testing.T) {
+	for mode, ok := range map[string]bool{outputText: true, outputJSON: true, "yaml": false} {
+		if err := validateOutputMode(mode); (err == nil) != ok {
+			t.Errorf("validateOutputMode(%q) error = %v", mode, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Color depths a terminal can display
+const (
+	colorDepth16 = iota
+	colorDepth256
+	colorDepthTrue
+)
+
+// colorAuto is the scheme value that gives every name its own stable color
+const colorAuto = "auto"
+
+// basicNameColors is the palette for names on terminals limited to 16 colors.
+// Red is left out so that names are not mistaken for errors.
+var basicNameColors = []string{
+	ColorGreen, ColorYellow, ColorBlue, ColorMagenta, ColorCyan,
+	"\033[92m", "\033[93m", "\033[94m", "\033[95m", "\033[96m",
+}
+
+// nameColors is the palette names are hashed into, sized for the terminal
+var nameColors = namePalette(detectColorDepth(os.Getenv))
+
+// detectColorDepth guesses the color depth of the terminal from its environment
+func detectColorDepth(getenv func(string) string) int {
+	switch strings.ToLower(getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return colorDepthTrue
+	}
+	if strings.Contains(getenv("TERM"), "256color") {
+		return colorDepth256
+	}
+	return colorDepth16
+}
+
+// namePalette builds the palette of readable name colors for a color depth
+func namePalette(depth int) []string {
+	switch depth {
+	case colorDepthTrue:
+		// Evenly spaced hues at a lightness readable on dark and light backgrounds
+		var palette []string
+		for hue := 0; hue < 360; hue += 10 {
+			r, g, b := hslToRGB(float64(hue), 0.65, 0.6)
+			palette = append(palette, fmt.Sprintf("\033[38;2;%d;%d;%dm", r, g, b))
+		}
+		return palette
+	case colorDepth256:
+		// The 6x6x6 color cube without the darkest and the grey entries
+		var palette []string
+		for r := 0; r < 6; r++ {
+			for g := 0; g < 6; g++ {
+				for b := 0; b < 6; b++ {
+					if max(r, g, b) < 3 || (r == g && g == b) {
+						continue
+					}
+					palette = append(palette, fmt.Sprintf("\033[38;5;%dm", 16+36*r+6*g+b))
+				}
+			}
+		}
+		return palette
+	default:
+		return basicNameColors
+	}
+}
+
+// hslToRGB converts a hue in degrees and saturation/lightness in [0, 1] to RGB
+func hslToRGB(h, s, l float64) (int, int, int) {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	scale := func(v float64) int { return int(math.Round((v + m) * 255)) }
+	return scale(r), scale(g), scale(b)
+}
+
+// colorizeName colors a name with the scheme color of its role, or with a
+// stable color of its own when the role is set to "auto"
+func colorizeName(name, color string) string {
+	if color == colorAuto {
+		color = colorForName(name, nameColors)
+	}
+	return colorize(name, color)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectColorDepth(t *testing.T) {
+	tests := []struct {
+		name      string
+		colorterm string
+		term      string
+		want      int
+	}{
+		{"truecolor", "truecolor", "xterm-256color", colorDepthTrue},
+		{"24bit", "24bit", "xterm", colorDepthTrue},
+		{"256 colors", "", "xterm-256color", colorDepth256},
+		{"basic terminal", "", "xterm", colorDepth16},
+		{"no terminal info", "", "", colorDepth16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"COLORTERM": tt.colorterm, "TERM": tt.term}
+			if got := detectColorDepth(func(key string) string { return env[key] }); got != tt.want {
+				t.Errorf("detectColorDepth() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamePalette(t *testing.T) {
+	tests := []struct {
+		depth  int
+		prefix string
+	}{
+		{colorDepthTrue, "\033[38;2;"},
+		{colorDepth256, "\033[38;5;"},
+		{colorDepth16, "\033["},
+	}
+
+	for _, tt := range tests {
+		palette := namePalette(tt.depth)
+		if len(palette) < 10 {
+			t.Errorf("namePalette(%d) has %d colors, want at least 10", tt.depth, len(palette))
+		}
+		seen := make(map[string]bool)
+		for _, color := range palette {
+			if !strings.HasPrefix(color, tt.prefix) {
+				t.Errorf("namePalette(%d) color %q does not start with %q", tt.depth, color, tt.prefix)
+			}
+			if seen[color] {
+				t.Errorf("namePalette(%d) repeats %q", tt.depth, color)
+			}
+			seen[color] = true
+		}
+	}
+}
+
+func TestHSLToRGB(t *testing.T) {
+	tests := []struct {
+		h, s, l float64
+		r, g, b int
+	}{
+		{0, 1, 0.5, 255, 0, 0},
+		{120, 1, 0.5, 0, 255, 0},
+		{240, 1, 0.5, 0, 0, 255},
+		{0, 0, 1, 255, 255, 255},
+	}
+
+	for _, tt := range tests {
+		r, g, b := hslToRGB(tt.h, tt.s, tt.l)
+		if r != tt.r || g != tt.g || b != tt.b {
+			t.Errorf("hslToRGB(%v, %v, %v) = %d,%d,%d, want %d,%d,%d", tt.h, tt.s, tt.l, r, g, b, tt.r, tt.g, tt.b)
+		}
+	}
+}
+
+func TestColorizeNameAuto(t *testing.T) {
+	first := colorizeName("api-7d9f-abcde", colorAuto)
+	if first != colorizeName("api-7d9f-abcde", colorAuto) {
+		t.Error("colorizeName() is not stable for the same name")
+	}
+
+	// With a handful of pods at least two must end up with different colors
+	colors := make(map[string]bool)
+	for _, name := range []string{"api-1", "api-2", "api-3", "worker-1", "worker-2"} {
+		colors[strings.TrimSuffix(colorizeName(name, colorAuto), name+ColorReset)] = true
+	}
+	if len(colors) < 2 {
+		t.Errorf("colorizeName() gave every pod the same color")
+	}
+
+	if got := colorizeName("api", ColorGreen); got != ColorGreen+"api"+ColorReset {
+		t.Errorf("colorizeName() with a fixed color = %q", got)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// logEntry is a log line parsed into fields
+type logEntry struct {
+	// Parser is the name of the parser that recognized the line
+	Parser string
+	Fields map[string]any
+	// Keys lists the fields in the order the line presents them
+	Keys []string
+}
+
+// Well-known keys for the time, level and message of a log entry, in order of preference
+var (
+	entryTimeKeys    = []string{"time", "ts", "timestamp", "@timestamp", "time_local", "start_time"}
+	entryLevelKeys   = []string{"level", "lvl", "severity"}
+	entryMessageKeys = []string{"msg", "message"}
+)
+
+// lineParser turns a raw log line into a logEntry when it recognizes the line's format
+type lineParser struct {
+	name  string
+	parse func(line string) (*logEntry, bool)
+}
+
+// lineParsers is the registry of known log formats, tried in order.
+// Supporting another format only takes adding it here.
+var lineParsers = []lineParser{
+	{"json", parseJSONEntry},
+	{"logfmt", parseLogfmtEntry},
+	{"nginx", parseNginxEntry},
+	{"envoy", parseEnvoyEntry},
+}
+
+// parserNames returns the names of the registered parsers
+func parserNames() []string {
+	var names []string
+	for _, parser := range lineParsers {
+		names = append(names, parser.name)
+	}
+	return names
+}
+
+// selectParsers looks up the named parsers; "none" disables parsing
+func selectParsers(names []string) ([]lineParser, error) {
+	var parsers []lineParser
+	for _, name := range names {
+		if name == "none" {
+			return nil, nil
+		}
+		found := false
+		for _, parser := range lineParsers {
+			if parser.name == name {
+				parsers = append(parsers, parser)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown parser %q (expected one of %v)", name, parserNames())
+		}
+	}
+	return parsers, nil
+}
+
+// parseLogLine returns the entry of the first parser recognizing the line, or nil
+func parseLogLine(line string, parsers []lineParser) *logEntry {
+	for _, parser := range parsers {
+		if entry, ok := parser.parse(line); ok {
+			entry.Parser = parser.name
+			return entry
+		}
+	}
+	return nil
+}
+
+// key returns the first of the keys present in the entry
+func (e *logEntry) key(keys []string) string {
+	for _, key := range keys {
+		if _, ok := e.Fields[key]; ok {
+			return key
+		}
+	}
+	return ""
+}
+
+// level returns the normalized severity of the entry, or "" when it has none
+func (e *logEntry) level() string {
+	value, ok := e.Fields[e.key(entryLevelKeys)].(string)
+	if !ok {
+		return ""
+	}
+	return levelAliases[strings.ToLower(value)]
+}
+
+// parseJSONLine decodes a log line holding a single JSON object
+func parseJSONLine(line string) (map[string]any, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") || !strings.HasSuffix(trimmed, "}") {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+	var fields map[string]any
+	if err := decoder.Decode(&fields); err != nil || decoder.More() {
+		return nil, false
+	}
+	return fields, true
+}
+
+// parseJSONEntry parses a JSON object line; keys are sorted as objects are unordered
+func parseJSONEntry(line string) (*logEntry, bool) {
+	fields, ok := parseJSONLine(line)
+	if !ok {
+		return nil, false
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return &logEntry{Fields: fields, Keys: keys}, true
+}
+
+// parseLogfmtEntry parses a logfmt line such as `ts=... level=info msg="started server" port=8080`.
+// Every token must be a key=value pair and at least two are required, so prose is not mistaken for logfmt.
+func parseLogfmtEntry(line string) (*logEntry, bool) {
+	entry := &logEntry{Fields: make(map[string]any)}
+	rest := strings.TrimSpace(line)
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 || strings.ContainsAny(rest[:eq], " \t\"") {
+			return nil, false
+		}
+		key := rest[:eq]
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := closingQuote(rest)
+			if end < 0 {
+				return nil, false
+			}
+			unquoted, err := unquoteLogfmt(rest[:end+1])
+			if err != nil {
+				return nil, false
+			}
+			value = unquoted
+			rest = rest[end+1:]
+			if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+				return nil, false
+			}
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			value = rest[:end]
+			rest = rest[end:]
+		}
+
+		if _, seen := entry.Fields[key]; !seen {
+			entry.Keys = append(entry.Keys, key)
+		}
+		entry.Fields[key] = value
+		rest = strings.TrimLeft(rest, " \t")
+	}
+
+	if len(entry.Keys) < 2 {
+		return nil, false
+	}
+	return entry, true
+}
+
+// closingQuote returns the index of the quote closing the string that s starts with, or -1
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unquoteLogfmt decodes a quoted logfmt value, which uses JSON string escapes
+func unquoteLogfmt(quoted string) (string, error) {
+	var value string
+	err := json.Unmarshal([]byte(quoted), &value)
+	return value, err
+}
+
+// accessLogParser builds a parser for an access log format from a regex with named groups.
+// The level of a request is derived from its status code.
+func accessLogParser(re *regexp.Regexp, statusKey string) func(string) (*logEntry, bool) {
+	return func(line string) (*logEntry, bool) {
+		match := re.FindStringSubmatch(line)
+		if match == nil {
+			return nil, false
+		}
+
+		entry := &logEntry{Fields: make(map[string]any)}
+		for i, key := range re.SubexpNames() {
+			if i == 0 || key == "" {
+				continue
+			}
+			entry.Keys = append(entry.Keys, key)
+			entry.Fields[key] = match[i]
+		}
+
+		status, _ := entry.Fields[statusKey].(string)
+		entry.Fields["level"] = statusLevel(status)
+		entry.Keys = append(entry.Keys, "level")
+		return entry, true
+	}
+}
+
+// statusLevel maps an HTTP status code to a severity level
+func statusLevel(status string) string {
+	switch {
+	case strings.HasPrefix(status, "5"):
+		return levelError
+	case strings.HasPrefix(status, "4"):
+		return levelWarn
+	}
+	return levelInfo
+}
+
+// nginxPattern matches the nginx "combined" access log format
+var nginxPattern = regexp.MustCompile(`^(?P<remote_addr>\S+) - (?P<remote_user>\S+) \[(?P<time_local>[^\]]+)\] "(?P<method>[A-Z]+) (?P<path>\S+) (?P<protocol>[^"]+)" (?P<status>\d{3}) (?P<bytes_sent>\d+|-) "(?P<referer>[^"]*)" "(?P<user_agent>[^"]*)"`)
+
+// envoyPattern matches Envoy's default access log format
+var envoyPattern = regexp.MustCompile(`^\[(?P<start_time>[^\]]+)\] "(?P<method>[A-Z]+) (?P<path>\S+) (?P<protocol>[^"]+)" (?P<response_code>\d{3}) (?P<response_flags>\S+) (?P<bytes_received>\d+) (?P<bytes_sent>\d+) (?P<duration>\d+) (?P<upstream_service_time>\S+) "(?P<x_forwarded_for>[^"]*)" "(?P<user_agent>[^"]*)" "(?P<request_id>[^"]*)" "(?P<authority>[^"]*)" "(?P<upstream_host>[^"]*)"`)
+
+var (
+	parseNginxEntry = accessLogParser(nginxPattern, "status")
+	parseEnvoyEntry = accessLogParser(envoyPattern, "response_code")
+)
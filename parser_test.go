@@ -0,0 +1,127 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		parser string
+		fields map[string]any
+	}{
+		{
+			name:   "json",
+			line:   `{"level":"info","msg":"started"}`,
+			parser: "json",
+			fields: map[string]any{"level": "info", "msg": "started"},
+		},
+		{
+			name:   "logfmt with quoted values",
+			line:   `level=warn msg="slow \"query\"" took=1.5s`,
+			parser: "logfmt",
+			fields: map[string]any{"level": "warn", "msg": `slow "query"`, "took": "1.5s"},
+		},
+		{
+			name:   "nginx combined",
+			line:   `10.0.0.1 - alice [01/Mar/2024:09:00:00 +0000] "POST /login HTTP/2.0" 401 12 "https://example.com/" "Mozilla/5.0"`,
+			parser: "nginx",
+			fields: map[string]any{
+				"remote_addr": "10.0.0.1", "remote_user": "alice", "time_local": "01/Mar/2024:09:00:00 +0000",
+				"method": "POST", "path": "/login", "protocol": "HTTP/2.0", "status": "401", "bytes_sent": "12",
+				"referer": "https://example.com/", "user_agent": "Mozilla/5.0", "level": levelWarn,
+			},
+		},
+		{
+			name:   "envoy default",
+			line:   `[2024-03-01T09:00:00.000Z] "GET /api HTTP/1.1" 200 - 0 512 12 10 "10.0.0.1" "curl/8.0" "abc-123" "api.example.com" "10.1.2.3:8080"`,
+			parser: "envoy",
+			fields: map[string]any{
+				"start_time": "2024-03-01T09:00:00.000Z", "method": "GET", "path": "/api", "protocol": "HTTP/1.1",
+				"response_code": "200", "response_flags": "-", "bytes_received": "0", "bytes_sent": "512",
+				"duration": "12", "upstream_service_time": "10", "x_forwarded_for": "10.0.0.1",
+				"user_agent": "curl/8.0", "request_id": "abc-123", "authority": "api.example.com",
+				"upstream_host": "10.1.2.3:8080", "level": levelInfo,
+			},
+		},
+		{name: "prose", line: "Starting server on port 8080"},
+		{name: "single pair is not logfmt", line: "retries=3"},
+		{name: "prose with an equals sign", line: "set x=1 for the job"},
+		{name: "unterminated quote", line: `level=info msg="oops`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := parseLogLine(tt.line, lineParsers)
+			if tt.parser == "" {
+				if entry != nil {
+					t.Fatalf("parseLogLine() parsed %q as %s", tt.line, entry.Parser)
+				}
+				return
+			}
+			if entry == nil {
+				t.Fatalf("parseLogLine() did not parse %q", tt.line)
+			}
+			if entry.Parser != tt.parser {
+				t.Errorf("parser = %q, want %q", entry.Parser, tt.parser)
+			}
+			fields := make(map[string]any)
+			for key, value := range entry.Fields {
+				fields[key] = value
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+			if len(entry.Keys) != len(entry.Fields) {
+				t.Errorf("keys = %v, want one per field", entry.Keys)
+			}
+		})
+	}
+}
+
+func TestLogfmtKeepsKeyOrder(t *testing.T) {
+	entry, ok := parseLogfmtEntry(`ts=1 msg=hello b=2 a=1`)
+	if !ok {
+		t.Fatal("parseLogfmtEntry() failed")
+	}
+	if want := []string{"ts", "msg", "b", "a"}; !reflect.DeepEqual(entry.Keys, want) {
+		t.Errorf("keys = %v, want %v", entry.Keys, want)
+	}
+}
+
+func TestLogEntryLevel(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`{"severity":"WARNING"}`, levelWarn},
+		{`lvl=eror msg=typo`, ""},
+		{`lvl=debug msg=x`, levelDebug},
+		{`{"msg":"no level"}`, ""},
+	}
+
+	for _, tt := range tests {
+		entry := parseLogLine(tt.line, lineParsers)
+		if entry == nil {
+			t.Fatalf("parseLogLine(%q) = nil", tt.line)
+		}
+		if got := entry.level(); got != tt.want {
+			t.Errorf("level(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSelectParsers(t *testing.T) {
+	parsers, err := selectParsers([]string{"logfmt", "json"})
+	if err != nil || len(parsers) != 2 || parsers[0].name != "logfmt" {
+		t.Errorf("selectParsers(logfmt,json) = %v, %v", parsers, err)
+	}
+	if parsers, err := selectParsers([]string{"none"}); err != nil || parsers != nil {
+		t.Errorf("selectParsers(none) = %v, %v, want no parsers", parsers, err)
+	}
+	if _, err := selectParsers([]string{"syslog"}); err == nil {
+		t.Error("selectParsers(syslog) expected an error")
+	}
+}
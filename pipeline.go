@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// logPipeline filters, formats and prints log lines, whether they are streamed
+// from a cluster or replayed from saved logs
+type logPipeline struct {
+	out           io.Writer
+	showCluster   bool
+	showContainer bool
+	timestamps    *timestampFormatter
+	formatter     *entryFormatter
+	highlighter   *highlighter
+	renderer      *lineRenderer
+	filter        *lineFilter
+}
+
+// newLogPipeline sets up the pipeline from the output flags
+func newLogPipeline(out io.Writer, showCluster, showContainer bool) (*logPipeline, error) {
+	p := &logPipeline{
+		out:           out,
+		showCluster:   showCluster,
+		showContainer: showContainer,
+		// Parsed log lines are reshaped with --format, --fields and --json-expand
+		formatter: newEntryFormatter(outputFormat, splitCommaList(fieldList), jsonExpand),
+		// Include patterns are highlighted along with the --highlight keywords
+		highlighter: &highlighter{
+			patterns: append(append([]*regexp.Regexp{}, includeRegexes...), highlightRegexes...),
+			levels:   levelColors,
+		},
+		// With --include/--exclude, lines are filtered per stream
+		filter: newLineFilter(includeRegexes, excludeRegexes, contextBefore, contextAfter),
+	}
+
+	// With --timestamps, each line is preceded by when it was logged
+	if showTimestamps {
+		formatter, err := newTimestampFormatter(timeFormat, timeLocation)
+		if err != nil {
+			return nil, err
+		}
+		p.timestamps = formatter
+	}
+
+	// With --template, each log line is laid out by the user's template
+	if lineTemplate != nil {
+		formatter, err := newTimestampFormatter(timeFormat, timeLocation)
+		if err != nil {
+			return nil, err
+		}
+		p.renderer = &lineRenderer{tmpl: lineTemplate, timestamps: formatter}
+	}
+
+	return p, nil
+}
+
+// process parses a log line and applies --where and --include/--exclude,
+// returning the lines to print: none, the line itself, or it with its context
+func (p *logPipeline) process(logLine LogLine) []LogLine {
+	if !logLine.Time.IsZero() {
+		logLine.Entry = parseLogLine(logLine.Line, enabledParsers)
+		if whereFilter != nil && !whereFilter.eval(logLine.Line, logLine.Entry) {
+			return nil
+		}
+	}
+	if p.filter == nil {
+		return []LogLine{logLine}
+	}
+	return p.filter.apply(logLine)
+}
+
+// print writes a log line in the selected output mode
+func (p *logPipeline) print(logLine LogLine) {
+	if outputMode == outputJSON {
+		printJSONLine(p.out, logLine, p.showCluster, p.showContainer)
+		return
+	}
+	if p.renderer != nil && !logLine.Time.IsZero() {
+		rendered, err := p.renderer.render(logLine)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to render template for %s/%s: %v\n", logLine.PodInfo.Namespace, logLine.PodInfo.Name, err)
+			return
+		}
+		fmt.Fprintln(p.out, rendered)
+		return
+	}
+
+	// Format: [timestamp] [cluster:namespace/pod/container] log line with colors
+	prefix := formatPrefix(logLine.PodInfo, p.showCluster, p.showContainer)
+	if p.timestamps != nil {
+		if stamp := p.timestamps.format(logLine.Time); stamp != "" {
+			prefix = colorizeTimestamp(stamp) + " " + prefix
+		}
+	}
+	line := logLine.Line
+	if logLine.Styled != "" {
+		line = logLine.Styled
+	}
+	if !logLine.Time.IsZero() {
+		level := ""
+		if logLine.Entry != nil {
+			level = logLine.Entry.level()
+		}
+		if p.formatter != nil {
+			line = p.formatter.render(line, logLine.Entry)
+		}
+		line = p.highlighter.render(line, level)
+	}
+	fmt.Fprintf(p.out, "%s %s\n", prefix, line)
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A --where query is a boolean expression over the fields of a log line, e.g.
+//
+//	level>=warn && (status>=500 || path=~"^/api") && !"healthz"
+//
+// Comparisons are ==, !=, <, <=, >, >=, =~ (regex) and !~. Values compare as
+// numbers when both sides are numeric, as severities for the level field, and
+// as strings otherwise. A bare field name tests that the field is present and a
+// bare string tests that the raw line contains it. Lines no parser recognized
+// fall back to the raw line: regex comparisons match it, == and != test whether
+// it contains the value, and the level is detected in the text.
+
+// levelRanks orders the normalized severity levels
+var levelRanks = map[string]int{levelDebug: 0, levelInfo: 1, levelWarn: 2, levelError: 3}
+
+// queryNode is a node of a parsed --where expression
+type queryNode interface {
+	eval(line string, entry *logEntry) bool
+}
+
+// andNode, orNode and notNode combine expressions
+type (
+	andNode struct{ left, right queryNode }
+	orNode  struct{ left, right queryNode }
+	notNode struct{ node queryNode }
+)
+
+func (n andNode) eval(line string, entry *logEntry) bool {
+	return n.left.eval(line, entry) && n.right.eval(line, entry)
+}
+
+func (n orNode) eval(line string, entry *logEntry) bool {
+	return n.left.eval(line, entry) || n.right.eval(line, entry)
+}
+
+func (n notNode) eval(line string, entry *logEntry) bool {
+	return !n.node.eval(line, entry)
+}
+
+// containsNode is a bare string, true when the raw line contains it
+type containsNode struct{ text string }
+
+func (n containsNode) eval(line string, entry *logEntry) bool {
+	return strings.Contains(line, n.text)
+}
+
+// existsNode is a bare field name, true when the line has that field
+type existsNode struct{ field string }
+
+func (n existsNode) eval(line string, entry *logEntry) bool {
+	_, ok := lookupField(line, entry, n.field)
+	return ok
+}
+
+// compareNode compares a field with a value
+type compareNode struct {
+	field string
+	op    string
+	value string
+	regex *regexp.Regexp
+}
+
+func (n compareNode) eval(line string, entry *logEntry) bool {
+	if n.field == "level" && n.regex == nil {
+		if rank, ok := levelRanks[levelAliases[strings.ToLower(n.value)]]; ok {
+			return n.compareLevel(lineLevel(line, entry), rank)
+		}
+	}
+
+	actual, ok := lookupField(line, entry, n.field)
+	if !ok {
+		if entry == nil {
+			return n.matchRaw(line)
+		}
+		// A missing field only satisfies negative comparisons
+		return n.op == "!=" || n.op == "!~"
+	}
+	return n.compare(actual)
+}
+
+// compareLevel compares the severity of a line with a level rank
+func (n compareNode) compareLevel(level string, rank int) bool {
+	actual, ok := levelRanks[level]
+	if !ok {
+		return n.op == "!=" || n.op == "!~"
+	}
+	return compareOrdered(actual-rank, n.op)
+}
+
+// matchRaw applies the comparison to the raw text of an unstructured line
+func (n compareNode) matchRaw(line string) bool {
+	switch n.op {
+	case "=~":
+		return n.regex.MatchString(line)
+	case "!~":
+		return !n.regex.MatchString(line)
+	case "==":
+		return strings.Contains(line, n.value)
+	case "!=":
+		return !strings.Contains(line, n.value)
+	}
+	return false
+}
+
+// compare applies the comparison to a field value
+func (n compareNode) compare(actual string) bool {
+	switch n.op {
+	case "=~":
+		return n.regex.MatchString(actual)
+	case "!~":
+		return !n.regex.MatchString(actual)
+	}
+
+	a, errA := strconv.ParseFloat(actual, 64)
+	b, errB := strconv.ParseFloat(n.value, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			return compareOrdered(-1, n.op)
+		case a > b:
+			return compareOrdered(1, n.op)
+		}
+		return compareOrdered(0, n.op)
+	}
+	return compareOrdered(strings.Compare(actual, n.value), n.op)
+}
+
+// compareOrdered turns the sign of a comparison into the result of an operator
+func compareOrdered(cmp int, op string) bool {
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// lineLevel returns the severity of a line from its fields, or detected in its text
+func lineLevel(line string, entry *logEntry) string {
+	if entry != nil {
+		if level := entry.level(); level != "" {
+			return level
+		}
+	}
+	level, _, _, _ := detectLevel(line)
+	return level
+}
+
+// lookupField returns a field of the line as text. Dotted names reach into
+// nested objects, and "line" is the raw line unless the entry has such a field.
+func lookupField(line string, entry *logEntry, field string) (string, bool) {
+	if entry != nil {
+		if value, ok := entry.Fields[field]; ok {
+			return plainFieldValue(value), true
+		}
+		var value any = entry.Fields
+		for _, part := range strings.Split(field, ".") {
+			object, ok := value.(map[string]any)
+			if !ok {
+				value = nil
+				break
+			}
+			value = object[part]
+		}
+		if value != nil {
+			return plainFieldValue(value), true
+		}
+	}
+	if field == "line" {
+		return line, true
+	}
+	return "", false
+}
+
+// queryToken is a lexical token of a --where expression
+type queryToken struct {
+	kind  string // "word", "string", "op" or "end"
+	text  string
+	index int
+}
+
+// queryOperators are the operators and punctuation of the query language, longest first
+var queryOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "=", "!", "(", ")"}
+
+// tokenizeQuery splits a --where expression into tokens
+func tokenizeQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	i := 0
+	for i < len(query) {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			text, end, err := readQuotedString(query, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{"string", text, i})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range queryOperators {
+				if strings.HasPrefix(query[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op != "" {
+				tokens = append(tokens, queryToken{"op", op, i})
+				i += len(op)
+				continue
+			}
+			start := i
+			for i < len(query) && !strings.ContainsRune(" \t\n\"'()&|!=<>~", rune(query[i])) {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected %q at position %d", query[i], i)
+			}
+			tokens = append(tokens, queryToken{"word", query[start:i], start})
+		}
+	}
+	return append(tokens, queryToken{"end", "", len(query)}), nil
+}
+
+// readQuotedString reads the string starting at a quote and returns it with the index after it.
+// A backslash escapes the quote or another backslash; other backslashes are kept for regexes.
+func readQuotedString(query string, start int) (string, int, error) {
+	quote := query[start]
+	var b strings.Builder
+	for i := start + 1; i < len(query); i++ {
+		c := query[i]
+		if c == '\\' && i+1 < len(query) && (query[i+1] == quote || query[i+1] == '\\') {
+			b.WriteByte(query[i+1])
+			i++
+			continue
+		}
+		if c == quote {
+			return b.String(), i + 1, nil
+		}
+		b.WriteByte(c)
+	}
+	return "", 0, fmt.Errorf("unterminated string at position %d", start)
+}
+
+// queryParser is a recursive descent parser for --where expressions
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+// parseQuery compiles a --where expression
+func parseQuery(query string) (queryNode, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != "end" {
+		return nil, fmt.Errorf("unexpected %q at position %d", next.text, next.index)
+	}
+	return node, nil
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	token := p.tokens[p.pos]
+	if token.kind != "end" {
+		p.pos++
+	}
+	return token
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == "op" && p.peek().text == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == "op" && p.peek().text == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	token := p.next()
+	switch {
+	case token.kind == "op" && token.text == "!":
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node}, nil
+	case token.kind == "op" && token.text == "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != "op" || closing.text != ")" {
+			return nil, fmt.Errorf("missing ) at position %d", closing.index)
+		}
+		return node, nil
+	case token.kind == "string":
+		return containsNode{token.text}, nil
+	case token.kind == "word":
+		return p.parseComparison(token.text)
+	case token.kind == "end":
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", token.text, token.index)
+}
+
+func (p *queryParser) parseComparison(field string) (queryNode, error) {
+	op := p.peek()
+	if op.kind != "op" || !isComparison(op.text) {
+		return existsNode{field}, nil
+	}
+	p.next()
+
+	value := p.next()
+	if value.kind != "word" && value.kind != "string" {
+		return nil, fmt.Errorf("missing value after %s at position %d", op.text, value.index)
+	}
+
+	node := compareNode{field: field, op: op.text, value: value.text}
+	if node.op == "=" {
+		node.op = "=="
+	}
+	if node.op == "=~" || node.op == "!~" {
+		re, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %v", value.text, err)
+		}
+		node.regex = re
+	} else if field == "level" && node.op != "==" && node.op != "!=" {
+		if _, ok := levelAliases[strings.ToLower(value.text)]; !ok {
+			return nil, fmt.Errorf("unknown level %q", value.text)
+		}
+	}
+	return node, nil
+}
+
+// isComparison reports whether an operator compares a field with a value
+func isComparison(op string) bool {
+	switch op {
+	case "==", "=", "!=", "<", "<=", ">", ">=", "=~", "!~":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package main
+
+import "testing"
+
+func TestParseQueryEval(t *testing.T) {
+	jsonLine := `{"level":"error","status":503,"path":"/api/orders","http":{"method":"POST"},"msg":"upstream timeout"}`
+	logfmtLine := `level=info status=200 path=/healthz msg=ok`
+	plainLine := `2024/03/01 WARN disk usage at 91%`
+
+	tests := []struct {
+		query string
+		line  string
+		want  bool
+	}{
+		{`level>=warn`, jsonLine, true},
+		{`level>=warn`, logfmtLine, false},
+		{`level>=warn`, plainLine, true},
+		{`level==error`, jsonLine, true},
+		{`level<info`, logfmtLine, false},
+		{`status>=500`, jsonLine, true},
+		{`status>=500`, logfmtLine, false},
+		{`status>=500 && path=~"^/api"`, jsonLine, true},
+		{`status>=500 && path=~'^/health'`, jsonLine, false},
+		{`level>=warn && status>=500 && path=~"^/api"`, jsonLine, true},
+		{`status<300 || level==error`, logfmtLine, true},
+		{`!(path=="/healthz")`, logfmtLine, false},
+		{`path!="/healthz"`, jsonLine, true},
+		{`http.method==POST`, jsonLine, true},
+		{`msg=="upstream timeout"`, jsonLine, true},
+		{`trace_id`, jsonLine, false},
+		{`trace_id!=abc`, jsonLine, true},
+		{`"timeout"`, jsonLine, true},
+		{`!"healthz"`, logfmtLine, false},
+		{`status=~"^5\d\d$"`, jsonLine, true},
+		{`status>=500`, plainLine, false},
+		{`msg=~"disk usage at 9\d%"`, plainLine, true},
+		{`msg=="disk usage"`, plainLine, true},
+		{`msg!="disk usage"`, plainLine, false},
+		{`line=~"^2024"`, plainLine, true},
+		{`path<"/b"`, jsonLine, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			node, err := parseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parseQuery(%q) error: %v", tt.query, err)
+			}
+			entry := parseLogLine(tt.line, lineParsers)
+			if got := node.eval(tt.line, entry); got != tt.want {
+				t.Errorf("eval(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQueryPrecedence(t *testing.T) {
+	// && binds tighter than ||
+	node, err := parseQuery(`level==debug && status==1 || status==200`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `level=info status=200`
+	if !node.eval(line, parseLogLine(line, lineParsers)) {
+		t.Error("expected a || b && c to group as (a && b) || c")
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, query := range []string{
+		``,
+		`level>=`,
+		`(level==error`,
+		`level==error)`,
+		`path=~"["`,
+		`msg=="unterminated`,
+		`level>=loud`,
+		`a & b`,
+		`&& level==error`,
+	} {
+		if _, err := parseQuery(query); err == nil {
+			t.Errorf("parseQuery(%q) expected an error", query)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// activeStream is a running container log stream and the function that stops it
+type activeStream struct {
+	cancel context.CancelFunc
+}
+
+// streamRegistry tracks the active log streams, giving each one its own
+// cancellable context so that a single stream can be stopped precisely
+type streamRegistry struct {
+	ctx     context.Context
+	logChan chan<- LogLine
+
+	mu      sync.Mutex
+	streams map[string]*activeStream
+	// Every container attached so far, so that one that finished for good is not streamed again
+	attached map[string]bool
+	started  int
+	failed   int
+
+	wg sync.WaitGroup
+}
+
+// newStreamRegistry creates a registry whose streams are children of ctx and write to logChan
+func newStreamRegistry(ctx context.Context, logChan chan<- LogLine) *streamRegistry {
+	return &streamRegistry{
+		ctx:      ctx,
+		logChan:  logChan,
+		streams:  make(map[string]*activeStream),
+		attached: make(map[string]bool),
+	}
+}
+
+// start launches a log stream for a pod container unless one is already running.
+// It reports whether a new stream was started.
+func (r *streamRegistry) start(clientset *kubernetes.Clientset, pod PodInfo) bool {
+	key := streamKey(pod)
+
+	r.mu.Lock()
+	if _, ok := r.streams[key]; ok {
+		r.mu.Unlock()
+		return false
+	}
+	ctx, cancel := context.WithCancel(r.ctx)
+	stream := &activeStream{cancel: cancel}
+	r.streams[key] = stream
+	r.attached[key] = true
+	r.started++
+	r.wg.Add(1)
+	r.mu.Unlock()
+
+	go func() {
+		defer r.wg.Done()
+		defer r.remove(key, stream)
+		if err := streamPodLogs(clientset, pod, r.logChan, ctx); err != nil {
+			r.mu.Lock()
+			r.failed++
+			r.mu.Unlock()
+		}
+	}()
+	return true
+}
+
+// wasAttached reports whether a stream was ever started for a pod container
+func (r *streamRegistry) wasAttached(pod PodInfo) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.attached[streamKey(pod)]
+}
+
+// wait blocks until every stream started so far has finished
+func (r *streamRegistry) wait() {
+	r.wg.Wait()
+}
+
+// startedCount returns how many streams have been started
+func (r *streamRegistry) startedCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.started
+}
+
+// failedCount returns how many streams ended with an error
+func (r *streamRegistry) failedCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failed
+}
+
+// remove forgets a finished stream so the container can be attached again later
+func (r *streamRegistry) remove(key string, stream *activeStream) {
+	stream.cancel()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.streams[key] == stream {
+		delete(r.streams, key)
+	}
+}
+
+// stopPod cancels the streams of every container of a pod and returns how many were stopped.
+// A later pod of the same name is attached afresh.
+func (r *streamRegistry) stopPod(cluster, namespace, name string) int {
+	prefix := streamKey(PodInfo{Cluster: cluster, Namespace: namespace, Name: name})
+
+	r.mu.Lock()
+	for key := range r.attached {
+		if strings.HasPrefix(key, prefix) {
+			delete(r.attached, key)
+		}
+	}
+	var stopped []*activeStream
+	for key, stream := range r.streams {
+		if strings.HasPrefix(key, prefix) {
+			stopped = append(stopped, stream)
+			delete(r.streams, key)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, stream := range stopped {
+		stream.cancel()
+	}
+	return len(stopped)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// addTestStream registers a fake stream and returns its context
+func addTestStream(r *streamRegistry, pod PodInfo) context.Context {
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.streams[streamKey(pod)] = &activeStream{cancel: cancel}
+	r.attached[streamKey(pod)] = true
+	return ctx
+}
+
+func TestStreamRegistryStopPod(t *testing.T) {
+	registry := newStreamRegistry(context.Background(), make(chan LogLine))
+
+	app := PodInfo{Cluster: "eu", Namespace: "shop", Name: "checkout-1", Container: "app"}
+	proxy := PodInfo{Cluster: "eu", Namespace: "shop", Name: "checkout-1", Container: "istio-proxy"}
+	other := PodInfo{Cluster: "eu", Namespace: "shop", Name: "checkout-10", Container: "app"}
+
+	appCtx := addTestStream(registry, app)
+	proxyCtx := addTestStream(registry, proxy)
+	otherCtx := addTestStream(registry, other)
+
+	if stopped := registry.stopPod("eu", "shop", "checkout-1"); stopped != 2 {
+		t.Errorf("stopPod() stopped %d streams, want 2", stopped)
+	}
+	if appCtx.Err() == nil || proxyCtx.Err() == nil {
+		t.Errorf("stopPod() did not cancel the streams of the deleted pod")
+	}
+	if otherCtx.Err() != nil {
+		t.Errorf("stopPod() cancelled the stream of another pod")
+	}
+	if _, ok := registry.streams[streamKey(app)]; ok {
+		t.Errorf("stopPod() left a stream of the deleted pod in the registry")
+	}
+	if _, ok := registry.streams[streamKey(other)]; !ok {
+		t.Errorf("stopPod() removed the stream of another pod from the registry")
+	}
+}
+
+func TestStreamRegistryRemoveKeepsNewerStream(t *testing.T) {
+	registry := newStreamRegistry(context.Background(), make(chan LogLine))
+	pod := PodInfo{Cluster: "eu", Namespace: "shop", Name: "checkout-1", Container: "app"}
+
+	addTestStream(registry, pod)
+	old := registry.streams[streamKey(pod)]
+
+	// The pod is deleted and a pod of the same name attached before the old goroutine exits
+	registry.stopPod(pod.Cluster, pod.Namespace, pod.Name)
+	addTestStream(registry, pod)
+	registry.remove(streamKey(pod), old)
+
+	if _, ok := registry.streams[streamKey(pod)]; !ok {
+		t.Errorf("remove() of a stale stream dropped the newer stream")
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// replaySpeed is how many times faster than logged ktail replay plays lines back, 0 for no delay
+var replaySpeed float64
+
+var replayCmd = &cobra.Command{
+	Use:   "replay FILE|DIR|ARCHIVE ...",
+	Short: "Play saved logs back through ktail's filters and formatting",
+	Long: `replay reads logs saved earlier and prints them as if they were tailed live,
+merged in timestamp order and shaped by the same flags as ktail itself.
+
+It reads JSON Lines written by -o json, directories written by --output-dir,
+bundles written by ktail capture (as .tar.gz or extracted), and single log files
+whose lines start with an RFC3339 timestamp. The namespace, pod and container
+of a file are taken from its <namespace>/<pod>/<container>.log path.
+
+Lines are printed at once unless --speed is given: 1 keeps the original pace,
+10 plays them back ten times faster.
+
+Examples:
+  ktail replay ktail-capture-20240301-090000.tar.gz --where 'level>=warn'
+  ktail replay ./incident -n shop -c app --since-time 09:00 --until 09:05
+  ktail replay checkout.jsonl --speed 10 --time-format relative`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runReplay,
+}
+
+func init() {
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 0, "Keep the original spacing of lines, sped up this many times, e.g. 1 or 10 (0 prints them at once)")
+	addOutputFlags(replayCmd)
+	rootCmd.AddCommand(replayCmd)
+}
+
+func runReplay(cmd *cobra.Command, args []string) {
+	// Validate the output flags first, status messages depend on the output mode
+	parseOutputFlags(cmd)
+
+	if replaySpeed < 0 {
+		fmt.Fprintf(os.Stderr, "--speed cannot be negative\n")
+		os.Exit(1)
+	}
+	if selector != "" {
+		fmt.Fprintf(os.Stderr, "--selector cannot be used with replay, saved logs carry no pod labels\n")
+		os.Exit(1)
+	}
+
+	parseSelectionFlags(cmd)
+
+	start := sinceTime
+	if sinceDuration > 0 {
+		start = time.Now().Add(-sinceDuration)
+	}
+	// Saved logs are replayed whole unless a tail limit is given explicitly
+	tail := -1
+	if cmd.Flags().Changed("tail") {
+		tail = tailLines
+	}
+
+	lines, err := loadReplayLines(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read logs: %v\n", err)
+		os.Exit(1)
+	}
+	lines = selectReplayLines(lines, splitCommaList(namespace), start, untilTime, tail)
+	if len(lines) == 0 {
+		fmt.Fprintf(os.Stderr, "No log lines to replay\n")
+		os.Exit(1)
+	}
+
+	// Show the cluster and container in the prefix when the logs span several
+	showCluster, showContainer := replayPrefixes(lines)
+	showContainer = showContainer || allContainers || initContainers || containerRegex != nil
+	printStatus("Replaying %d line(s) from %d stream(s)\n", len(lines), countReplayStreams(lines))
+
+	pipeline, err := newLogPipeline(os.Stdout, showCluster, showContainer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to replay logs: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	replayLines(ctx, pipeline, lines, replaySpeed)
+}
+
+// replayLines feeds log lines through the pipeline. With a speed, each line waits
+// for its original offset from the first one divided by the speed.
+func replayLines(ctx context.Context, pipeline *logPipeline, lines []LogLine, speed float64) {
+	started := time.Now()
+	for _, logLine := range lines {
+		if speed > 0 {
+			offset := time.Duration(float64(logLine.Time.Sub(lines[0].Time)) / speed)
+			if wait := time.Until(started.Add(offset)); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		for _, line := range pipeline.process(logLine) {
+			pipeline.print(line)
+		}
+	}
+}
+
+// selectReplayLines keeps the lines of the selected namespaces and containers within
+// the time window, and with a tail limit only the last lines of each stream
+func selectReplayLines(lines []LogLine, namespaces []string, start, until time.Time, tail int) []LogLine {
+	var selected []LogLine
+	for _, line := range lines {
+		pod := line.PodInfo
+		if len(namespaces) > 0 && !containsString(namespaces, pod.Namespace) {
+			continue
+		}
+		if (container != "" && pod.Container != container) || (containerRegex != nil && !containerRegex.MatchString(pod.Container)) {
+			continue
+		}
+		if (!start.IsZero() && line.Time.Before(start)) || (!until.IsZero() && line.Time.After(until)) {
+			continue
+		}
+		selected = append(selected, line)
+	}
+	if tail < 0 {
+		return selected
+	}
+
+	// Walk backwards to find the last lines of each stream, then keep them in order
+	remaining := make(map[string]int)
+	keep := make([]bool, len(selected))
+	for i := len(selected) - 1; i >= 0; i-- {
+		key := streamKey(selected[i].PodInfo)
+		if _, ok := remaining[key]; !ok {
+			remaining[key] = tail
+		}
+		if remaining[key] > 0 {
+			remaining[key]--
+			keep[i] = true
+		}
+	}
+	var tailed []LogLine
+	for i, line := range selected {
+		if keep[i] {
+			tailed = append(tailed, line)
+		}
+	}
+	return tailed
+}
+
+// containsString reports whether a list contains a value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// replayPrefixes reports whether the lines come from several clusters, and from several containers of a pod
+func replayPrefixes(lines []LogLine) (bool, bool) {
+	clusters := make(map[string]bool)
+	containers := make(map[string]string)
+	showContainer := false
+	for _, line := range lines {
+		pod := line.PodInfo
+		clusters[pod.Cluster] = true
+		key := pod.Cluster + "/" + pod.Namespace + "/" + pod.Name
+		if seen, ok := containers[key]; ok && seen != pod.Container {
+			showContainer = true
+		}
+		containers[key] = pod.Container
+	}
+	return len(clusters) > 1, showContainer
+}
+
+// countReplayStreams returns the number of container streams the lines come from
+func countReplayStreams(lines []LogLine) int {
+	streams := make(map[string]bool)
+	for _, line := range lines {
+		streams[streamKey(line.PodInfo)] = true
+	}
+	return len(streams)
+}
+
+// loadReplayLines reads the log lines of every file, directory and bundle given, merged in timestamp order
+func loadReplayLines(paths []string) ([]LogLine, error) {
+	var lines []LogLine
+	for _, p := range paths {
+		loaded, err := loadReplayPath(p)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, loaded...)
+	}
+	// Lines logged at the same time keep the order they were read in
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Time.Before(lines[j].Time) })
+	return lines, nil
+}
+
+// loadReplayPath reads the log lines of a file, a directory or a capture bundle
+func loadReplayPath(p string) ([]LogLine, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return loadReplayDir(p)
+	}
+	if strings.HasSuffix(p, ".tar.gz") || strings.HasSuffix(p, ".tgz") {
+		return loadCaptureBundle(p)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readReplayFile(f, p, replayPodInfo(filepath.ToSlash(filepath.Dir(p)), filepath.Base(p)))
+}
+
+// loadReplayDir reads the log files below a directory written by --output-dir or an extracted capture bundle
+func loadReplayDir(dir string) ([]LogLine, error) {
+	var lines []LogLine
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isReplayFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		loaded, err := readReplayFile(f, p, replayPodInfo(filepath.ToSlash(dir), filepath.ToSlash(rel)))
+		if err != nil {
+			return err
+		}
+		lines = append(lines, loaded...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest, err := readCaptureManifest(filepath.Join(dir, "manifest.json")); err == nil {
+		applyCaptureManifest(lines, manifest)
+	}
+	return lines, nil
+}
+
+// readCaptureManifest reads the manifest.json of an extracted capture bundle
+func readCaptureManifest(name string) (*captureManifest, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var manifest captureManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// loadCaptureBundle reads the logs of a tar.gz bundle written by ktail capture
+func loadCaptureBundle(name string) ([]LogLine, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	defer zr.Close()
+
+	var lines []LogLine
+	var manifest *captureManifest
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// Entries live under the bundle's top-level directory
+		_, rel, _ := strings.Cut(path.Clean(header.Name), "/")
+		switch {
+		case rel == "manifest.json":
+			manifest = &captureManifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("%s: %v", header.Name, err)
+			}
+		case isReplayFile(rel):
+			loaded, err := readReplayFile(tr, header.Name, replayPodInfo("", rel))
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, loaded...)
+		}
+	}
+
+	if manifest != nil {
+		applyCaptureManifest(lines, manifest)
+	}
+	return lines, nil
+}
+
+// applyCaptureManifest fills in the cluster and node of lines read from a capture bundle.
+// A bundle of several clusters keeps each one's files in a directory named after it.
+func applyCaptureManifest(lines []LogLine, manifest *captureManifest) {
+	pods := make(map[string]capturedPod)
+	for _, pod := range manifest.Pods {
+		dir := ""
+		if len(manifest.Clusters) > 1 {
+			dir = safePathComponent(pod.Cluster)
+		}
+		pods[dir+"/"+pod.Namespace+"/"+pod.Name] = pod
+	}
+	for i := range lines {
+		info := &lines[i].PodInfo
+		if pod, ok := pods[info.Cluster+"/"+info.Namespace+"/"+info.Name]; ok {
+			info.Cluster = pod.Cluster
+			info.Node = pod.Node
+		}
+	}
+}
+
+// isReplayFile reports whether a file name looks like saved logs: .log or .jsonl, optionally gzipped
+func isReplayFile(name string) bool {
+	name = strings.TrimSuffix(name, ".gz")
+	return strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".jsonl")
+}
+
+// rotatedSuffix matches the time stamp --output-dir adds to the names of rotated files
+var rotatedSuffix = regexp.MustCompile(`-\d{8}T\d{6}(\.\d+)?$`)
+
+// replayPodInfo derives the stream of a log file from its path, laid out as
+// [cluster/][logs/]<namespace>/<pod>/<container>.log by --output-dir and ktail capture.
+// rel is the path below the directory or bundle being replayed, root the path to it;
+// the namespace and pod may come from root, but only rel can name a cluster.
+func replayPodInfo(root, rel string) PodInfo {
+	parts := strings.Split(rel, "/")
+	file := strings.TrimSuffix(parts[len(parts)-1], ".gz")
+	file = strings.TrimSuffix(strings.TrimSuffix(file, ".log"), ".jsonl")
+	file = strings.TrimSuffix(file, ".previous")
+	pod := PodInfo{Container: rotatedSuffix.ReplaceAllString(file, "")}
+	parts = parts[:len(parts)-1]
+
+	var rootParts []string
+	for _, part := range strings.Split(root, "/") {
+		if part != "" && part != "." && part != ".." {
+			rootParts = append(rootParts, part)
+		}
+	}
+	next := func() string {
+		if n := len(parts); n > 0 {
+			part := parts[n-1]
+			parts = parts[:n-1]
+			return part
+		}
+		if n := len(rootParts); n > 0 {
+			part := rootParts[n-1]
+			rootParts = rootParts[:n-1]
+			return part
+		}
+		return ""
+	}
+	pod.Name = next()
+	pod.Namespace = next()
+
+	if n := len(parts); n > 0 && parts[n-1] == "logs" {
+		parts = parts[:n-1]
+	}
+	if n := len(parts); n > 0 {
+		pod.Cluster = parts[n-1]
+	}
+	if pod.Name == "" {
+		pod.Name = pod.Container
+	}
+	return pod
+}
+
+// readReplayFile reads the lines of a saved log file, decompressing it when its name ends in .gz
+func readReplayFile(r io.Reader, name string, pod PodInfo) ([]LogLine, error) {
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	lines, skipped, err := readReplayLines(r, pod)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d line(s) without a timestamp at the start of %s\n", skipped, name)
+	}
+	return lines, nil
+}
+
+// readReplayLines parses saved log lines: JSON records written by -o json, which
+// name their own pod, or lines prefixed with an RFC3339 timestamp as written by
+// --output-dir and ktail capture. A line without a timestamp continues the one
+// before it, and is skipped along with the number returned when none came before.
+func readReplayLines(r io.Reader, pod PodInfo) ([]LogLine, int, error) {
+	var lines []LogLine
+	var last time.Time
+	skipped := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if line, ok := parseJSONRecordLine(text); ok {
+			last = line.Time
+			lines = append(lines, line)
+			continue
+		}
+
+		ts, rest, ok := splitTimestamp(text)
+		if !ok {
+			if last.IsZero() {
+				skipped++
+				continue
+			}
+			ts, rest = last, text
+		}
+		last = ts
+		lines = append(lines, LogLine{PodInfo: pod, Time: ts, Line: rest})
+	}
+	return lines, skipped, scanner.Err()
+}
+
+// parseJSONRecordLine decodes a line written by -o json back into a log line
+func parseJSONRecordLine(text string) (LogLine, bool) {
+	if !strings.HasPrefix(text, "{") {
+		return LogLine{}, false
+	}
+	var record jsonRecord
+	if err := json.Unmarshal([]byte(text), &record); err != nil || record.Pod == "" || record.Timestamp == "" {
+		return LogLine{}, false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, record.Timestamp)
+	if err != nil {
+		return LogLine{}, false
+	}
+	return LogLine{
+		PodInfo: PodInfo{
+			Cluster:   record.Cluster,
+			Namespace: record.Namespace,
+			Name:      record.Pod,
+			Container: record.Container,
+			Node:      record.Node,
+		},
+		Time: ts,
+		Line: record.Message,
+	}, true
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayPodInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		root string
+		rel  string
+		want PodInfo
+	}{
+		{"output dir", "incident", "shop/api-1/app.log", PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}},
+		{"output dir of several clusters", "incident", "prod/shop/api-1/app.log", PodInfo{Cluster: "prod", Namespace: "shop", Name: "api-1", Container: "app"}},
+		{"rotated file", "incident", "shop/api-1/app-20240301T090000.1.log.gz", PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}},
+		{"capture bundle", "", "logs/shop/api-1/app.previous.log", PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}},
+		{"capture bundle of several clusters", "", "eu/logs/shop/api-1/app.log", PodInfo{Cluster: "eu", Namespace: "shop", Name: "api-1", Container: "app"}},
+		{"single file", "/tmp/incident/shop/api-1", "app.log", PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}},
+		{"bare file", ".", "app.log", PodInfo{Name: "app", Container: "app"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replayPodInfo(tt.root, tt.rel); got != tt.want {
+				t.Errorf("replayPodInfo(%q, %q) = %+v, want %+v", tt.root, tt.rel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadReplayLines(t *testing.T) {
+	pod := PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}
+	input := strings.Join([]string{
+		"banner without a timestamp",
+		"2024-03-01T09:00:00Z started",
+		"  continued",
+		`{"cluster":"prod","namespace":"shop","pod":"web-1","container":"nginx","node":"node-a","timestamp":"2024-03-01T09:00:01Z","message":"GET /"}`,
+		`{"level":"info"}`,
+	}, "\n")
+
+	lines, skipped, err := readReplayLines(strings.NewReader(input), pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 1 {
+		t.Errorf("skipped = %d, want 1", skipped)
+	}
+
+	first := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	second := first.Add(time.Second)
+	want := []LogLine{
+		{PodInfo: pod, Time: first, Line: "started"},
+		{PodInfo: pod, Time: first, Line: "  continued"},
+		{PodInfo: PodInfo{Cluster: "prod", Namespace: "shop", Name: "web-1", Container: "nginx", Node: "node-a"}, Time: second, Line: "GET /"},
+		{PodInfo: pod, Time: second, Line: `{"level":"info"}`},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %+v", len(lines), len(want), lines)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(lines[i], want[i]) {
+			t.Errorf("line %d = %+v, want %+v", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestSelectReplayLines(t *testing.T) {
+	base := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	api := PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}
+	db := PodInfo{Namespace: "data", Name: "db-0", Container: "postgres"}
+	lines := []LogLine{
+		{PodInfo: api, Time: base, Line: "a1"},
+		{PodInfo: db, Time: base.Add(time.Second), Line: "d1"},
+		{PodInfo: api, Time: base.Add(2 * time.Second), Line: "a2"},
+		{PodInfo: api, Time: base.Add(3 * time.Second), Line: "a3"},
+		{PodInfo: db, Time: base.Add(4 * time.Second), Line: "d2"},
+	}
+
+	tests := []struct {
+		name       string
+		namespaces []string
+		start      time.Time
+		until      time.Time
+		tail       int
+		want       []string
+	}{
+		{"everything", nil, time.Time{}, time.Time{}, -1, []string{"a1", "d1", "a2", "a3", "d2"}},
+		{"namespace", []string{"shop"}, time.Time{}, time.Time{}, -1, []string{"a1", "a2", "a3"}},
+		{"time window", nil, base.Add(time.Second), base.Add(3 * time.Second), -1, []string{"d1", "a2", "a3"}},
+		{"tail per stream", nil, time.Time{}, time.Time{}, 1, []string{"a3", "d2"}},
+		{"tail within window", nil, time.Time{}, base.Add(3 * time.Second), 2, []string{"d1", "a2", "a3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, line := range selectReplayLines(lines, tt.namespaces, tt.start, tt.until, tt.tail) {
+				got = append(got, line.Line)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectReplayLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayOutputDir(t *testing.T) {
+	noColor = true
+	defer func() { noColor = false }()
+
+	// Save two streams with rotation, then replay them merged in timestamp order
+	dir := t.TempDir()
+	base := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	w := newLogFileWriter(dir, false, 0, 0, true)
+	w.now = func() time.Time { return base }
+	api := PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}
+	web := PodInfo{Namespace: "shop", Name: "web-1", Container: "nginx"}
+	w.write(LogLine{PodInfo: api, Time: base, Line: "api started"})
+	w.write(LogLine{PodInfo: web, Time: base.Add(time.Second), Line: "web started"})
+	w.maxAge = time.Minute
+	w.now = func() time.Time { return base.Add(time.Hour) }
+	w.write(LogLine{PodInfo: api, Time: base.Add(2 * time.Second), Line: "api ready"})
+	w.close()
+
+	lines, err := loadReplayLines([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	pipeline, err := newLogPipeline(&out, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayLines(t.Context(), pipeline, lines, 0)
+
+	want := "[shop/api-1] api started\n[shop/web-1] web started\n[shop/api-1] api ready\n"
+	if out.String() != want {
+		t.Errorf("replayed output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestReplayCaptureBundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := newCaptureBundle(file, "ktail-capture-test", time.Now())
+	if err := bundle.addBytes("logs/shop/api-1/app.log", []byte("2024-03-01T09:00:01Z second\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bundle.addBytes("logs/shop/api-1/app.previous.log", []byte("2024-03-01T09:00:00Z first\n")); err != nil {
+		t.Fatal(err)
+	}
+	manifest := &captureManifest{
+		Clusters: []string{"prod"},
+		Pods:     []capturedPod{{Cluster: "prod", Namespace: "shop", Name: "api-1", Node: "node-a"}},
+	}
+	if err := bundle.addJSON("manifest.json", manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := bundle.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := loadReplayLines([]string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := PodInfo{Cluster: "prod", Namespace: "shop", Name: "api-1", Container: "app", Node: "node-a"}
+	want := []LogLine{
+		{PodInfo: pod, Time: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), Line: "first"},
+		{PodInfo: pod, Time: time.Date(2024, 3, 1, 9, 0, 1, 0, time.UTC), Line: "second"},
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("loadReplayLines() = %+v, want %+v", lines, want)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// colorScheme holds the ANSI sequences used for each part of the output
+type colorScheme struct {
+	Namespace string
+	Pod       string
+	Container string
+	Timestamp string
+	Highlight string
+	Error     string
+	Warn      string
+	Info      string
+	Debug     string
+}
+
+// colorSchemes are the built-in schemes selectable with --color-scheme
+var colorSchemes = map[string]colorScheme{
+	"default": {
+		Namespace: ColorGreen,
+		Pod:       colorAuto,
+		Container: colorAuto,
+		Timestamp: ColorGray,
+		Highlight: ColorHighlight,
+		Error:     ColorRed,
+		Warn:      ColorYellow,
+		Info:      ColorGreen,
+		Debug:     ColorGray,
+	},
+	"bright": {
+		Namespace: "\033[92m",
+		Pod:       colorAuto,
+		Container: colorAuto,
+		Timestamp: "\033[37m",
+		Highlight: "\033[1;7m",
+		Error:     "\033[1;91m",
+		Warn:      "\033[1;93m",
+		Info:      "\033[92m",
+		Debug:     "\033[37m",
+	},
+	"subtle": {
+		Namespace: ColorBlue,
+		Pod:       ColorBlue,
+		Container: ColorGray,
+		Timestamp: ColorGray,
+		Highlight: "\033[4m",
+		Error:     ColorRed,
+		Warn:      ColorYellow,
+		Info:      "",
+		Debug:     ColorGray,
+	},
+}
+
+// scheme is the color scheme in use
+var scheme = colorSchemes["default"]
+
+// colorCodes maps color names to SGR parameters
+var colorCodes = map[string]string{
+	"bold":           "1",
+	"dim":            "2",
+	"italic":         "3",
+	"underline":      "4",
+	"reverse":        "7",
+	"black":          "30",
+	"red":            "31",
+	"green":          "32",
+	"yellow":         "33",
+	"blue":           "34",
+	"magenta":        "35",
+	"cyan":           "36",
+	"white":          "37",
+	"gray":           "90",
+	"bright-red":     "91",
+	"bright-green":   "92",
+	"bright-yellow":  "93",
+	"bright-blue":    "94",
+	"bright-magenta": "95",
+	"bright-cyan":    "96",
+	"bright-white":   "97",
+}
+
+// loadColorScheme returns a built-in scheme with the given overrides applied.
+// Overrides are comma separated role=color pairs such as "error=bold+red,pod=208".
+func loadColorScheme(name, overrides string) (colorScheme, error) {
+	selected, ok := colorSchemes[name]
+	if !ok {
+		var names []string
+		for n := range colorSchemes {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return colorScheme{}, fmt.Errorf("unknown color scheme %q (expected one of %v)", name, names)
+	}
+
+	for _, override := range splitCommaList(overrides) {
+		role, spec, found := strings.Cut(override, "=")
+		if !found {
+			return colorScheme{}, fmt.Errorf("invalid color %q, expected role=color", override)
+		}
+		color, err := parseColor(spec)
+		if err != nil {
+			return colorScheme{}, err
+		}
+		role = strings.ToLower(strings.TrimSpace(role))
+		target := selected.role(role)
+		if target == nil {
+			return colorScheme{}, fmt.Errorf("unknown color role %q", role)
+		}
+		if color == colorAuto && role != "namespace" && role != "pod" && role != "container" {
+			return colorScheme{}, fmt.Errorf("only namespace, pod and container colors can be auto")
+		}
+		*target = color
+	}
+	return selected, nil
+}
+
+// role returns the scheme entry for a role name
+func (s *colorScheme) role(name string) *string {
+	switch name {
+	case "namespace":
+		return &s.Namespace
+	case "pod":
+		return &s.Pod
+	case "container":
+		return &s.Container
+	case "timestamp":
+		return &s.Timestamp
+	case "highlight":
+		return &s.Highlight
+	case "error":
+		return &s.Error
+	case "warn":
+		return &s.Warn
+	case "info":
+		return &s.Info
+	case "debug":
+		return &s.Debug
+	}
+	return nil
+}
+
+// parseColor turns a color spec into an ANSI sequence. A spec joins names like
+// "bold+red" with '+', and also accepts 256-color indexes ("208") and "#rrggbb".
+// "none" disables coloring for the role and "auto" gives each name its own color.
+func parseColor(spec string) (string, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "none":
+		return "", nil
+	case colorAuto:
+		return colorAuto, nil
+	}
+
+	var params []string
+	for _, part := range strings.Split(spec, "+") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if code, ok := colorCodes[part]; ok {
+			params = append(params, code)
+		} else if index, err := strconv.Atoi(part); err == nil && index >= 0 && index <= 255 {
+			params = append(params, "38;5;"+part)
+		} else if rgb, err := strconv.ParseUint(strings.TrimPrefix(part, "#"), 16, 32); err == nil && strings.HasPrefix(part, "#") && len(part) == 7 {
+			params = append(params, fmt.Sprintf("38;2;%d;%d;%d", rgb>>16, rgb>>8&0xff, rgb&0xff))
+		} else {
+			return "", fmt.Errorf("unknown color %q", part)
+		}
+	}
+	return "\033[" + strings.Join(params, ";") + "m", nil
+}
+
+// levelColor returns the color of a normalized severity level
+func (s colorScheme) levelColor(level string) string {
+	switch level {
+	case levelError:
+		return s.Error
+	case levelWarn:
+		return s.Warn
+	case levelInfo:
+		return s.Info
+	case levelDebug:
+		return s.Debug
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{"red", "\033[31m", false},
+		{"bold+red", "\033[1;31m", false},
+		{"208", "\033[38;5;208m", false},
+		{"#ff8000", "\033[38;2;255;128;0m", false},
+		{"none", "", false},
+		{"auto", colorAuto, false},
+		{"purple", "", true},
+		{"256", "", true},
+		{"#ff80", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseColor(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseColor(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseColor(%q) = %q, want %q", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadColorScheme(t *testing.T) {
+	s, err := loadColorScheme("default", "error=bold+red, pod=208")
+	if err != nil {
+		t.Fatalf("loadColorScheme error: %v", err)
+	}
+	if s.Error != "\033[1;31m" || s.Pod != "\033[38;5;208m" {
+		t.Errorf("overrides not applied: error=%q pod=%q", s.Error, s.Pod)
+	}
+	if s.Warn != colorSchemes["default"].Warn {
+		t.Errorf("warn = %q, want the default", s.Warn)
+	}
+
+	for _, tt := range []struct{ name, overrides string }{
+		{"neon", ""},
+		{"default", "error"},
+		{"default", "banner=red"},
+		{"default", "error=purple"},
+		{"default", "error=auto"},
+	} {
+		if _, err := loadColorScheme(tt.name, tt.overrides); err == nil {
+			t.Errorf("loadColorScheme(%q, %q) expected an error", tt.name, tt.overrides)
+		}
+	}
+}
@@ -3,18 +3,57 @@ package main
 import (
 	"context"
 	"fmt"
-	"strings"
+	"sort"
 
 	"github.com/ktr0731/go-fuzzyfinder"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
-// selectNamespace allows interactive selection of a single namespace
-func selectNamespace(clientset *kubernetes.Clientset) (string, error) {
+// selectContext allows interactive selection of a kubeconfig context, listing the current one first
+func selectContext(config *clientcmdapi.Config) (string, error) {
+	var contextList []string
+	if _, ok := config.Contexts[config.CurrentContext]; ok {
+		contextList = append(contextList, config.CurrentContext)
+	}
+
+	var others []string
+	for name := range config.Contexts {
+		if name != config.CurrentContext {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	contextList = append(contextList, others...)
+
+	return runFuzzyFinder(contextList, "Select context:")
+}
+
+// resolveNamespaces determines the target namespaces from flags or interactive selection
+func resolveNamespaces(clientset *kubernetes.Clientset) ([]string, error) {
+	if allNamespaces {
+		return []string{metav1.NamespaceAll}, nil
+	}
+	if namespace != "" {
+		return splitCommaList(namespace), nil
+	}
+	if multiSelect {
+		return selectNamespacesMulti(clientset)
+	}
+
+	ns, err := selectNamespace(clientset)
+	if err != nil {
+		return nil, err
+	}
+	return []string{ns}, nil
+}
+
+// listNamespaceNames retrieves the names of all namespaces in the cluster
+func listNamespaceNames(clientset *kubernetes.Clientset) ([]string, error) {
 	namespaces, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
-		return "", fmt.Errorf("failed to list namespaces: %v", err)
+		return nil, fmt.Errorf("failed to list namespaces: %v", err)
 	}
 
 	var namespaceList []string
@@ -22,18 +61,43 @@ func selectNamespace(clientset *kubernetes.Clientset) (string, error) {
 		namespaceList = append(namespaceList, ns.Name)
 	}
 
+	return namespaceList, nil
+}
+
+// selectNamespace allows interactive selection of a single namespace
+func selectNamespace(clientset *kubernetes.Clientset) (string, error) {
+	namespaceList, err := listNamespaceNames(clientset)
+	if err != nil {
+		return "", err
+	}
+
 	return runFuzzyFinder(namespaceList, "Select namespace:")
 }
 
-// selectPodsMulti allows interactive multi-selection of pods in a namespace
-func selectPodsMulti(clientset *kubernetes.Clientset, namespace string) ([]string, error) {
-	pods, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+// selectNamespacesMulti allows interactive multi-selection of namespaces
+func selectNamespacesMulti(clientset *kubernetes.Clientset) ([]string, error) {
+	namespaceList, err := listNamespaceNames(clientset)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list pods: %v", err)
+		return nil, err
 	}
 
+	return runFuzzyFinderMulti(namespaceList, "Select namespaces (use Tab to select multiple):")
+}
+
+// selectPodsMulti allows interactive multi-selection of pods across namespaces
+func selectPodsMulti(clientset *kubernetes.Clientset, namespaces []string) ([]PodInfo, error) {
+	pods, err := listPods(clientset, namespaces, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefix pod names with their namespace when more than one namespace is involved
+	showNamespace := len(namespaces) > 1 || namespaces[0] == metav1.NamespaceAll
+
 	var podList []string
-	for _, pod := range pods.Items {
+	podsByLine := make(map[string][]PodInfo)
+	for i := range pods {
+		pod := &pods[i]
 		status := string(pod.Status.Phase)
 		if pod.Status.Phase == "Running" {
 			status = "🟢 Running"
@@ -44,13 +108,18 @@ func selectPodsMulti(clientset *kubernetes.Clientset, namespace string) ([]strin
 		} else {
 			status = "⚪ " + status
 		}
-		podList = append(podList, fmt.Sprintf("%s\t%s", pod.Name, status))
+
+		name := pod.Name
+		if showNamespace {
+			name = pod.Namespace + "/" + pod.Name
+		}
+		line := fmt.Sprintf("%s\t%s", name, status)
+		podList = append(podList, line)
+		podsByLine[line] = expandPodContainers(pod)
 	}
 
-	// Check if there are any pods in the namespace
 	if len(podList) == 0 {
-		fmt.Printf("No pods found in namespace %s, skipping...\n", namespace)
-		return []string{}, nil
+		return []PodInfo{}, nil
 	}
 
 	selected, err := runFuzzyFinderMulti(podList, "Select pods (use Tab to select multiple):")
@@ -58,14 +127,13 @@ func selectPodsMulti(clientset *kubernetes.Clientset, namespace string) ([]strin
 		return nil, err
 	}
 
-	// Extract pod names from the selected lines
-	var podNames []string
+	// Map the selected lines back to their pod containers
+	var podInfos []PodInfo
 	for _, line := range selected {
-		parts := strings.Split(line, "\t")
-		podNames = append(podNames, parts[0])
+		podInfos = append(podInfos, podsByLine[line]...)
 	}
 
-	return podNames, nil
+	return podInfos, nil
 }
 
 // runFuzzyFinder runs a single-selection fuzzy finder
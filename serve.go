@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/websocket"
+)
+
+var (
+	listenAddr   string
+	historyLines int
+)
+
+// sseKeepAlive is how often an idle event stream gets a comment, so proxies keep it open
+const sseKeepAlive = 15 * time.Second
+
+// clientBufferSize is how many lines may queue up for a client before new ones are dropped
+const clientBufferSize = 1024
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [TYPE/NAME ...]",
+	Short: "Publish live logs to browsers and other HTTP clients over SSE and WebSocket",
+	Long: `serve selects and streams pods like ktail itself, following new pods as they
+appear, and publishes every log line to HTTP clients instead of the terminal:
+
+  /         a log viewer for the browser
+  /events   Server-Sent Events, one JSON record per event
+  /ws       WebSocket, one JSON record per text message
+
+Records are shaped like the output of -o json. ktail's own markers, such as
+container restarts, have no timestamp. Each client picks its own lines with
+query parameters: namespace (comma separated), pod and container (regexes),
+include and exclude (regexes, can be repeated) and where (a --where query).
+New clients first get the recent lines kept with --history. Only local clients
+can connect unless --listen binds another address.
+
+Examples:
+  ktail serve -n shop -l app=checkout
+  ktail serve -n shop -l app=checkout --listen :8080  # Reachable from other hosts
+  ktail serve -n shop deploy/checkout --history 5000
+  curl -N 'http://localhost:8080/events?where=level>=warn&pod=^checkout-'`,
+	Args: cobra.ArbitraryArgs,
+	Run:  runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&listenAddr, "listen", "localhost:8080", "Address to serve HTTP on, use :8080 to accept other hosts")
+	serveCmd.Flags().IntVar(&historyLines, "history", 1000, "Number of recent lines sent to each new client")
+	serveCmd.Flags().StringVar(&parserList, "parsers", strings.Join(parserNames(), ","), "Log formats to parse into fields, comma separated, or none")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) {
+	if historyLines < 0 {
+		fmt.Fprintf(os.Stderr, "--history cannot be negative\n")
+		os.Exit(1)
+	}
+
+	var err error
+	enabledParsers, err = selectParsers(splitCommaList(parserList))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid parsers: %v\n", err)
+		os.Exit(1)
+	}
+
+	clusters, err := createK8sClients()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create Kubernetes client: %v\n", err)
+		os.Exit(1)
+	}
+
+	parseSelectionFlags(cmd)
+	targetNamespaces := resolveTargetNamespaces(clusters)
+	allPods, watchTargets := collectClusterPods(clusters, targetNamespaces, args)
+
+	// Bind before streaming so that a taken port fails right away
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to listen on %s: %v\n", listenAddr, err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	hub := newLogHub(historyLines)
+	server := &http.Server{
+		Handler: newServeMux(hub),
+		// Open event streams end along with the server
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "Failed to serve: %v\n", err)
+			cancel()
+		}
+	}()
+
+	printStatus("Serving logs of %d pod(s) across %d namespace(s) on http://%s\n", len(allPods), countNamespaces(allPods), listener.Addr())
+	printStatus("Press Ctrl+C to stop...\n")
+
+	// Every pod is followed, including the ones that appear while serving
+	logChan := make(chan LogLine, 100)
+	startLogStreams(ctx, clusters, allPods, watchTargets, true, logChan)
+	for {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancelShutdown()
+			server.Shutdown(shutdownCtx)
+			return
+		case logLine := <-logChan:
+			if !logLine.Time.IsZero() {
+				logLine.Entry = parseLogLine(logLine.Line, enabledParsers)
+			}
+			hub.publish(logLine)
+		}
+	}
+}
+
+// newServeMux routes the viewer page and the SSE and WebSocket endpoints
+func newServeMux(hub *logHub) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", serveViewer)
+	mux.HandleFunc("/events", hub.serveEvents)
+	mux.Handle("/ws", websocket.Server{Handler: hub.serveWebSocket, Handshake: checkWebSocketOrigin})
+	return mux
+}
+
+// clientFilter picks the lines one client asked for with its query parameters
+type clientFilter struct {
+	namespaces []string
+	pod        *regexp.Regexp
+	container  *regexp.Regexp
+	where      queryNode
+	lines      *lineFilter
+}
+
+// parseClientFilter reads a client's filter from the namespace, pod, container,
+// include, exclude and where query parameters
+func parseClientFilter(query url.Values) (*clientFilter, error) {
+	filter := &clientFilter{namespaces: splitCommaList(query.Get("namespace"))}
+
+	var err error
+	if pattern := query.Get("pod"); pattern != "" {
+		if filter.pod, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid pod regex: %v", err)
+		}
+	}
+	if pattern := query.Get("container"); pattern != "" {
+		if filter.container, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid container regex: %v", err)
+		}
+	}
+	include, err := compilePatterns(nonEmptyValues(query["include"]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid include regex: %v", err)
+	}
+	exclude, err := compilePatterns(nonEmptyValues(query["exclude"]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude regex: %v", err)
+	}
+	filter.lines = newLineFilter(include, exclude, 0, 0)
+	if where := query.Get("where"); where != "" {
+		if filter.where, err = parseQuery(where); err != nil {
+			return nil, fmt.Errorf("invalid where query: %v", err)
+		}
+	}
+	return filter, nil
+}
+
+// nonEmptyValues drops the empty values of a query parameter, as sent by blank form fields
+func nonEmptyValues(values []string) []string {
+	var nonEmpty []string
+	for _, value := range values {
+		if value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+	return nonEmpty
+}
+
+// apply returns the line when the client wants it. Markers only go through the pod filters.
+func (f *clientFilter) apply(logLine LogLine) []LogLine {
+	pod := logLine.PodInfo
+	if len(f.namespaces) > 0 && !containsString(f.namespaces, pod.Namespace) {
+		return nil
+	}
+	if (f.pod != nil && !f.pod.MatchString(pod.Name)) || (f.container != nil && !f.container.MatchString(pod.Container)) {
+		return nil
+	}
+	if !logLine.Time.IsZero() && f.where != nil && !f.where.eval(logLine.Line, logLine.Entry) {
+		return nil
+	}
+	if f.lines == nil {
+		return []LogLine{logLine}
+	}
+	return f.lines.apply(logLine)
+}
+
+// logHub fans log lines out to the connected clients and keeps the recent ones for new clients
+type logHub struct {
+	mu      sync.Mutex
+	clients map[*hubClient]bool
+	history []hubLine
+	size    int
+	seq     uint64
+}
+
+// hubLine is a published log line numbered in publishing order, the id of its event
+type hubLine struct {
+	id   uint64
+	line LogLine
+}
+
+// hubClient is a connected client with its filter and queue of lines to send
+type hubClient struct {
+	filter *clientFilter
+	lines  chan hubLine
+	slow   bool
+}
+
+// newLogHub creates a hub that keeps the given number of recent lines
+func newLogHub(size int) *logHub {
+	return &logHub{clients: make(map[*hubClient]bool), size: size}
+}
+
+// subscribe registers a client and returns the recent lines it should get first,
+// those published after the given id when a client reconnects
+func (h *logHub) subscribe(filter *clientFilter, after uint64) (*hubClient, []hubLine) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	client := &hubClient{filter: filter, lines: make(chan hubLine, clientBufferSize)}
+	var backlog []hubLine
+	for _, published := range h.history {
+		if published.id <= after {
+			continue
+		}
+		for _, line := range filter.apply(published.line) {
+			backlog = append(backlog, hubLine{published.id, line})
+		}
+	}
+	h.clients[client] = true
+	return client, backlog
+}
+
+// unsubscribe removes a disconnected client
+func (h *logHub) unsubscribe(client *hubClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, client)
+}
+
+// publish hands a line to every client that wants it. Streaming never waits for
+// a client: one that falls too far behind misses lines instead.
+func (h *logHub) publish(logLine LogLine) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	if h.size > 0 {
+		h.history = append(h.history, hubLine{h.seq, logLine})
+		if len(h.history) > h.size {
+			h.history = h.history[len(h.history)-h.size:]
+		}
+	}
+
+	for client := range h.clients {
+		for _, line := range client.filter.apply(logLine) {
+			select {
+			case client.lines <- hubLine{h.seq, line}:
+			default:
+				if !client.slow {
+					fmt.Fprintf(os.Stderr, "A client is too slow to keep up, dropping lines for it\n")
+					client.slow = true
+				}
+			}
+		}
+	}
+}
+
+// serveEvents streams the lines a client asked for as Server-Sent Events
+func (h *logHub) serveEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseClientFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	// A reconnecting EventSource resumes after the last event it received
+	after, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	client, backlog := h.subscribe(filter, after)
+	defer h.unsubscribe(client)
+
+	for _, published := range backlog {
+		if err := writeEvent(w, published); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case published := <-client.lines:
+			if err := writeEvent(w, published); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes a log line as a Server-Sent Event carrying its JSON record
+func writeEvent(w io.Writer, published hubLine) error {
+	encoded, err := json.Marshal(newJSONRecord(published.line))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", published.id, encoded)
+	return err
+}
+
+// serveWebSocket streams the lines a client asked for as WebSocket text messages
+func (h *logHub) serveWebSocket(ws *websocket.Conn) {
+	defer ws.Close()
+
+	filter, err := parseClientFilter(ws.Request().URL.Query())
+	if err != nil {
+		websocket.JSON.Send(ws, map[string]string{"error": err.Error()})
+		return
+	}
+
+	client, backlog := h.subscribe(filter, 0)
+	defer h.unsubscribe(client)
+
+	for _, published := range backlog {
+		if err := websocket.JSON.Send(ws, newJSONRecord(published.line)); err != nil {
+			return
+		}
+	}
+
+	// Clients send nothing, reading only notices when they go away
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, ws)
+		close(closed)
+	}()
+
+	ctx := ws.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-closed:
+			return
+		case published := <-client.lines:
+			if err := websocket.JSON.Send(ws, newJSONRecord(published.line)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// checkWebSocketOrigin accepts clients that are not browsers, which send no Origin,
+// and pages served by ktail itself, but not other sites opened in the same browser
+func checkWebSocketOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if u.Host != r.Host {
+		return fmt.Errorf("cross-origin request from %s", origin)
+	}
+	config.Origin = u
+	return nil
+}
+
+// serveViewer serves the browser log viewer
+func serveViewer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, viewerPage)
+}
+
+// viewerPage is a log viewer that follows /events with the filters in its own query string
+const viewerPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>ktail</title>
+<style>
+  body { margin: 0; background: #1e1e1e; color: #d4d4d4; font: 13px/1.4 monospace; }
+  form { position: sticky; top: 0; display: flex; gap: 6px; padding: 6px; background: #2d2d2d; }
+  input { background: #1e1e1e; color: inherit; border: 1px solid #555; padding: 3px 6px; font: inherit; }
+  input[name=where] { flex: 1; }
+  #status { align-self: center; color: #888; }
+  #log { margin: 0; padding: 6px; white-space: pre-wrap; word-break: break-all; }
+  .pod { color: #4ec9b0; } .time { color: #808080; } .marker { color: #ce9178; }
+  .error { color: #f44747; } .warn { color: #dcdcaa; } .debug { color: #808080; }
+</style>
+</head>
+<body>
+<form>
+  <input name="namespace" placeholder="namespace">
+  <input name="pod" placeholder="pod regex">
+  <input name="include" placeholder="include regex">
+  <input name="exclude" placeholder="exclude regex">
+  <input name="where" placeholder="where, e.g. level>=warn">
+  <button>Apply</button>
+  <span id="status">connecting</span>
+</form>
+<pre id="log"></pre>
+<script>
+const params = new URLSearchParams(location.search);
+for (const input of document.querySelectorAll("input")) {
+  input.value = params.get(input.name) || "";
+}
+for (const [name, value] of [...params]) {
+  if (!value) params.delete(name);
+}
+const log = document.getElementById("log");
+const status = document.getElementById("status");
+const levels = { fatal: "error", panic: "error", critical: "error", error: "error", err: "error", warning: "warn", warn: "warn", debug: "debug", trace: "debug" };
+const maxLines = 5000;
+
+function levelOf(record) {
+  const fields = record.fields || {};
+  const level = String(fields.level || fields.lvl || fields.severity || "").toLowerCase();
+  if (levels[level]) return levels[level];
+  const match = /\b(FATAL|PANIC|CRITICAL|ERROR|ERR|WARNING|WARN|DEBUG|TRACE)\b/.exec(record.message);
+  return match ? levels[match[1].toLowerCase()] : "";
+}
+
+function append(record) {
+  const follow = window.innerHeight + window.scrollY >= document.body.scrollHeight - 20;
+  const line = document.createElement("div");
+  const pod = document.createElement("span");
+  pod.className = "pod";
+  pod.textContent = "[" + (record.cluster ? record.cluster + ":" : "") + record.namespace + "/" + record.pod + "/" + record.container + "] ";
+  if (record.timestamp) {
+    const time = document.createElement("span");
+    time.className = "time";
+    time.textContent = record.timestamp + " ";
+    line.append(time);
+  }
+  const message = document.createElement("span");
+  message.className = record.timestamp ? levelOf(record) : "marker";
+  message.textContent = record.message;
+  line.append(pod, message);
+  log.append(line);
+  while (log.childElementCount > maxLines) log.firstChild.remove();
+  if (follow) window.scrollTo(0, document.body.scrollHeight);
+}
+
+const source = new EventSource("events?" + params);
+source.onopen = () => { status.textContent = "live"; };
+source.onerror = () => { status.textContent = "reconnecting"; };
+source.onmessage = (event) => append(JSON.parse(event.data));
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClientFilter(t *testing.T) {
+	base := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	api := PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}
+	db := PodInfo{Namespace: "data", Name: "db-0", Container: "postgres"}
+	lines := []LogLine{
+		{PodInfo: api, Time: base, Line: `{"level":"info","msg":"GET /healthz"}`},
+		{PodInfo: api, Time: base, Line: `{"level":"error","msg":"timeout"}`},
+		{PodInfo: db, Time: base, Line: "ERROR deadlock detected"},
+		{PodInfo: db, Line: "container restarted"},
+	}
+	for i := range lines {
+		if !lines[i].Time.IsZero() {
+			lines[i].Entry = parseLogLine(lines[i].Line, lineParsers)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{0, 1, 2, 3}},
+		{"namespace=shop,web", []int{0, 1}},
+		{"pod=^db-&container=postgres", []int{2, 3}},
+		{"where=level>=error", []int{1, 2, 3}},
+		{"exclude=healthz&include=", []int{1, 2, 3}},
+		{"include=timeout&include=deadlock", []int{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter, err := parseClientFilter(query)
+			if err != nil {
+				t.Fatalf("parseClientFilter(%q) error: %v", tt.query, err)
+			}
+			var got []int
+			for i, line := range lines {
+				if len(filter.apply(line)) > 0 {
+					got = append(got, i)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filter %q kept lines %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseClientFilterErrors(t *testing.T) {
+	for _, query := range []string{"pod=(", "container=[", "include=(", "exclude=)", "where=level>=", "where=level>loud"} {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseClientFilter(values); err == nil {
+			t.Errorf("parseClientFilter(%q) succeeded, want an error", query)
+		}
+	}
+}
+
+func TestLogHubHistory(t *testing.T) {
+	hub := newLogHub(2)
+	pod := PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}
+	for _, text := range []string{"one", "two", "three"} {
+		hub.publish(LogLine{PodInfo: pod, Time: time.Now(), Line: text})
+	}
+
+	all, err := parseClientFilter(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		after uint64
+		want  []string
+	}{
+		{0, []string{"two", "three"}},
+		{2, []string{"three"}},
+		{3, nil},
+	}
+	for _, tt := range tests {
+		client, backlog := hub.subscribe(all, tt.after)
+		var got []string
+		for _, published := range backlog {
+			got = append(got, published.line.Line)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("subscribe(after %d) backlog = %v, want %v", tt.after, got, tt.want)
+		}
+		hub.unsubscribe(client)
+	}
+
+	client, _ := hub.subscribe(all, 0)
+	defer hub.unsubscribe(client)
+	hub.publish(LogLine{PodInfo: pod, Time: time.Now(), Line: "four"})
+	select {
+	case published := <-client.lines:
+		if published.id != 4 || published.line.Line != "four" {
+			t.Errorf("client got %d %q, want 4 \"four\"", published.id, published.line.Line)
+		}
+	default:
+		t.Error("client did not get the published line")
+	}
+}
+
+func TestPublishedMarkersHaveNoColors(t *testing.T) {
+	pod := PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}
+	logChan := make(chan LogLine, 1)
+	tracker := &podTracker{
+		logChan:       logChan,
+		restartCounts: map[string]int32{streamKey(pod): 1},
+	}
+	tracker.trackContainerRestarts(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "api-1"},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:                 "app",
+			RestartCount:         2,
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+		}}},
+	}, pod)
+
+	hub := newLogHub(10)
+	markers := []LogLine{podMarker(pod, "=== Starting logs for %s/%s (container: %s) ==="), <-logChan}
+	for _, marker := range markers {
+		if !strings.Contains(marker.Styled, "\033[") {
+			t.Errorf("marker %q has no colors for the terminal", marker.Styled)
+		}
+		hub.publish(marker)
+	}
+
+	all, err := parseClientFilter(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, backlog := hub.subscribe(all, 0)
+	defer hub.unsubscribe(client)
+	if len(backlog) != len(markers) {
+		t.Fatalf("backlog has %d lines, want %d", len(backlog), len(markers))
+	}
+	for _, published := range backlog {
+		var event strings.Builder
+		if err := writeEvent(&event, published); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(event.String(), "\\u001b") || strings.Contains(event.String(), "\033") {
+			t.Errorf("published marker carries escape codes: %q", event.String())
+		}
+	}
+	if want := "=== Container app restarted (restart #2): OOMKilled, exit code 137 ==="; backlog[1].line.Line != want {
+		t.Errorf("restart marker = %q, want %q", backlog[1].line.Line, want)
+	}
+}
+
+func TestServeEvents(t *testing.T) {
+	hub := newLogHub(10)
+	server := httptest.NewServer(newServeMux(hub))
+	defer server.Close()
+
+	ts := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	hub.publish(LogLine{PodInfo: PodInfo{Namespace: "shop", Name: "web-1", Container: "nginx"}, Time: ts, Line: "skipped"})
+	hub.publish(LogLine{PodInfo: PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}, Time: ts, Line: "hello"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events?pod=^api-", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var event []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+		event = append(event, line)
+	}
+	want := []string{"id: 2", `data: {"namespace":"shop","pod":"api-1","container":"app","timestamp":"2024-03-01T09:00:00Z","message":"hello"}`}
+	if !reflect.DeepEqual(event, want) {
+		t.Errorf("event = %q, want %q", event, want)
+	}
+
+	resp, err = http.Get(server.URL + "/events?where=level>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid filter status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestServeWebSocket(t *testing.T) {
+	hub := newLogHub(10)
+	server := httptest.NewServer(newServeMux(hub))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?namespace=shop"
+
+	if _, err := websocket.Dial(wsURL, "", "http://elsewhere.example"); err == nil {
+		t.Error("a cross-origin WebSocket was accepted")
+	}
+
+	ws, err := websocket.Dial(wsURL, "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// The line is published once the client is subscribed, either as history or live
+	ts := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	hub.publish(LogLine{PodInfo: PodInfo{Namespace: "data", Name: "db-0", Container: "postgres"}, Time: ts, Line: "skipped"})
+	hub.publish(LogLine{PodInfo: PodInfo{Namespace: "shop", Name: "api-1", Container: "app"}, Time: ts, Line: "hello"})
+
+	var message string
+	if err := websocket.Message.Receive(ws, &message); err != nil {
+		t.Fatal(err)
+	}
+	var record jsonRecord
+	if err := json.Unmarshal([]byte(message), &record); err != nil {
+		t.Fatal(err)
+	}
+	want := jsonRecord{Namespace: "shop", Pod: "api-1", Container: "app", Timestamp: "2024-03-01T09:00:00Z", Message: "hello"}
+	if !reflect.DeepEqual(record, want) {
+		t.Errorf("record = %+v, want %+v", record, want)
+	}
+}
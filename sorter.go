@@ -0,0 +1,84 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// sortedLine is a buffered log line waiting for its turn in the merged output
+type sortedLine struct {
+	line    LogLine
+	arrival time.Time
+	seq     uint64
+}
+
+// sortedLineHeap orders buffered lines by log timestamp, then by arrival order
+type sortedLineHeap []sortedLine
+
+func (h sortedLineHeap) Len() int { return len(h) }
+func (h sortedLineHeap) Less(i, j int) bool {
+	if !h[i].line.Time.Equal(h[j].line.Time) {
+		return h[i].line.Time.Before(h[j].line.Time)
+	}
+	return h[i].seq < h[j].seq
+}
+func (h sortedLineHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *sortedLineHeap) Push(x interface{}) { *h = append(*h, x.(sortedLine)) }
+func (h *sortedLineHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// logSorter merges lines from several pods in timestamp order. Each line is held
+// back for the sort window so that earlier lines from slower streams can overtake it.
+type logSorter struct {
+	window time.Duration
+	lines  sortedLineHeap
+	seq    uint64
+}
+
+// newLogSorter creates a sorter that buffers lines for the given window
+func newLogSorter(window time.Duration) *logSorter {
+	return &logSorter{window: window}
+}
+
+// push buffers a log line received at the given time
+func (s *logSorter) push(line LogLine, now time.Time) {
+	s.seq++
+	heap.Push(&s.lines, sortedLine{line: line, arrival: now, seq: s.seq})
+}
+
+// ready returns the lines that are due, in timestamp order. A line is due once
+// it has been buffered for the whole window, and so is every line stamped no
+// later than a due line, since printing it after that one would break the order.
+func (s *logSorter) ready(now time.Time) []LogLine {
+	var cutoff time.Time
+	due := false
+	for _, buffered := range s.lines {
+		if now.Sub(buffered.arrival) >= s.window && (!due || buffered.line.Time.After(cutoff)) {
+			cutoff = buffered.line.Time
+			due = true
+		}
+	}
+	if !due {
+		return nil
+	}
+
+	var lines []LogLine
+	for s.lines.Len() > 0 && !s.lines[0].line.Time.After(cutoff) {
+		lines = append(lines, heap.Pop(&s.lines).(sortedLine).line)
+	}
+	return lines
+}
+
+// flush returns every buffered line in timestamp order
+func (s *logSorter) flush() []LogLine {
+	var lines []LogLine
+	for s.lines.Len() > 0 {
+		lines = append(lines, heap.Pop(&s.lines).(sortedLine).line)
+	}
+	return lines
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogSorterOrdersWithinWindow(t *testing.T) {
+	base := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	now := base.Add(time.Hour)
+	sorter := newLogSorter(time.Second)
+
+	// Backlogs of two pods arrive one after the other
+	sorter.push(LogLine{PodInfo: PodInfo{Name: "a"}, Time: base.Add(1 * time.Millisecond), Line: "a1"}, now)
+	sorter.push(LogLine{PodInfo: PodInfo{Name: "a"}, Time: base.Add(3 * time.Millisecond), Line: "a3"}, now)
+	sorter.push(LogLine{PodInfo: PodInfo{Name: "b"}, Time: base.Add(2 * time.Millisecond), Line: "b2"}, now)
+	sorter.push(LogLine{PodInfo: PodInfo{Name: "b"}, Time: base.Add(3 * time.Millisecond), Line: "b3"}, now)
+
+	if lines := sorter.ready(now.Add(500 * time.Millisecond)); len(lines) != 0 {
+		t.Fatalf("ready() before the window elapsed returned %d lines", len(lines))
+	}
+
+	// A late line arriving inside the window still overtakes the buffered ones
+	sorter.push(LogLine{PodInfo: PodInfo{Name: "c"}, Time: base, Line: "c0"}, now.Add(600*time.Millisecond))
+
+	var got []string
+	for _, line := range sorter.ready(now.Add(time.Second)) {
+		got = append(got, line.Line)
+	}
+	expected := []string{"c0", "a1", "b2", "a3", "b3"}
+	if len(got) != len(expected) {
+		t.Fatalf("ready() = %v, want %v", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("ready() = %v, want %v", got, expected)
+		}
+	}
+}
+
+func TestLogSorterFlush(t *testing.T) {
+	base := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	sorter := newLogSorter(time.Minute)
+
+	sorter.push(LogLine{Time: base.Add(time.Second), Line: "second"}, base)
+	sorter.push(LogLine{Time: base, Line: "first"}, base)
+
+	lines := sorter.flush()
+	if len(lines) != 2 || lines[0].Line != "first" || lines[1].Line != "second" {
+		t.Errorf("flush() returned lines out of order: %v", lines)
+	}
+	if len(sorter.flush()) != 0 {
+		t.Errorf("flush() did not empty the sorter")
+	}
+}
@@ -3,20 +3,21 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
-func streamLogsWithWatch(clientset *kubernetes.Clientset, initialPods []PodInfo, namespace string, watch bool) error {
+func streamLogsWithWatch(clusters []ClusterClient, initialPods []PodInfo, targets []WatchTarget, watch bool) error {
 	// Set up signal handling for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -32,21 +33,59 @@ func streamLogsWithWatch(clientset *kubernetes.Clientset, initialPods []PodInfo,
 	// Create channels for log streaming
 	logChan := make(chan LogLine, 100)
 
-	// Track which pods are already being streamed to avoid duplicates
-	streamingPods := make(map[string]bool)
-	var streamingMutex sync.Mutex
+	// Start the streams, watching for new pods in watch mode
+	registry := startLogStreams(ctx, clusters, initialPods, targets, watch, logChan)
 
-	// Start streaming logs for initial pods
-	for _, pod := range initialPods {
-		podKey := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
-		streamingMutex.Lock()
-		streamingPods[podKey] = true
-		streamingMutex.Unlock()
-		go streamPodLogs(clientset, pod, logChan, ctx)
+	// Show the cluster in the prefix when tailing several clusters, and the
+	// container when more than one container per pod may be streamed
+	showCluster := len(clusters) > 1
+	showContainer := allContainers || initContainers || containerRegex != nil
+
+	// Without follow or with --until, stop once every stream has ended on its own
+	var done <-chan struct{}
+	if streamsEnd(watch) {
+		finished := make(chan struct{})
+		go func() {
+			registry.wait()
+			close(finished)
+		}()
+		done = finished
 	}
 
-	if watch {
-		go watchPodsWithTracking(clientset, namespace, logChan, ctx, &streamingPods, &streamingMutex)
+	// Every line goes through the same filtering and formatting as replayed logs
+	pipeline, err := newLogPipeline(os.Stdout, showCluster, showContainer)
+	if err != nil {
+		return err
+	}
+
+	// With --sort, timestamped lines are buffered and merged in chronological order
+	var sorter *logSorter
+	var sortTick <-chan time.Time
+	if sortLogs {
+		sorter = newLogSorter(sortWindow)
+		ticker := time.NewTicker(sortTickInterval(sortWindow))
+		defer ticker.Stop()
+		sortTick = ticker.C
+	}
+	// With --output-dir, every stream is saved unfiltered to a file of its own
+	var fileWriter *logFileWriter
+	if outputDir != "" {
+		fileWriter = newLogFileWriter(outputDir, showCluster, int64(rotateSize)*1024*1024, rotateInterval, gzipRotated)
+		defer fileWriter.close()
+	}
+
+	// Lines are filtered per stream before being sorted
+	handleLogLine := func(logLine LogLine) {
+		if fileWriter != nil {
+			fileWriter.write(logLine)
+		}
+		for _, line := range pipeline.process(logLine) {
+			if sorter != nil && !line.Time.IsZero() {
+				sorter.push(line, time.Now())
+				continue
+			}
+			pipeline.print(line)
+		}
 	}
 
 	// Process log lines from all pods
@@ -55,184 +94,384 @@ func streamLogsWithWatch(clientset *kubernetes.Clientset, initialPods []PodInfo,
 		case <-ctx.Done():
 			return nil
 		case logLine := <-logChan:
-			// Format: [namespace/pod] log line with colors
-			fmt.Printf("[%s/%s] %s\n",
-				colorizeNamespace(logLine.PodInfo.Namespace),
-				colorizePod(logLine.PodInfo.Name),
-				logLine.Line)
+			handleLogLine(logLine)
+		case now := <-sortTick:
+			for _, logLine := range sorter.ready(now) {
+				pipeline.print(logLine)
+			}
+		case <-done:
+			// Streams only finish after handing over their lines, print what is still buffered
+			for {
+				select {
+				case logLine := <-logChan:
+					handleLogLine(logLine)
+				default:
+					if sorter != nil {
+						for _, logLine := range sorter.flush() {
+							pipeline.print(logLine)
+						}
+					}
+					if failed := registry.failedCount(); failed > 0 {
+						return fmt.Errorf("%d of %d log stream(s) failed", failed, registry.startedCount())
+					}
+					return nil
+				}
+			}
 		}
 	}
 }
 
-// watchPodsWithTracking watches for pod changes and tracks streaming status
-func watchPodsWithTracking(clientset *kubernetes.Clientset, namespace string, logChan chan<- LogLine, ctx context.Context, streamingPods *map[string]bool, streamingMutex *sync.Mutex) {
-	watcher, err := clientset.CoreV1().Pods(namespace).Watch(ctx, metav1.ListOptions{})
+// startLogStreams streams the initial pods and, in watch mode, the pods that appear
+// later, sending their lines to logChan until ctx is cancelled
+func startLogStreams(ctx context.Context, clusters []ClusterClient, initialPods []PodInfo, targets []WatchTarget, watch bool, logChan chan LogLine) *streamRegistry {
+	// Track the active streams to avoid duplicates and to stop them individually
+	registry := newStreamRegistry(ctx, logChan)
+
+	// Look up the client for each cluster the logs are fanned in from
+	clientsets := make(map[string]*kubernetes.Clientset)
+	for _, cluster := range clusters {
+		clientsets[cluster.Name] = cluster.Clientset
+	}
+
+	// Start streaming logs for initial pods
+	// (pods matching several workloads are only streamed once)
+	for _, pod := range initialPods {
+		registry.start(clientsets[pod.Cluster], pod)
+	}
+
+	if watch {
+		// One watcher per target; an empty namespace watches the whole cluster
+		for _, target := range targets {
+			go watchPodsWithTracking(clientsets[target.Cluster], target, logChan, ctx, registry)
+		}
+	}
+	return registry
+}
+
+// sortTickInterval returns how often buffered lines are checked against the sort window
+func sortTickInterval(window time.Duration) time.Duration {
+	interval := window / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	return interval
+}
+
+// formatPrefix builds the bracketed prefix identifying the source of a log line
+func formatPrefix(pod PodInfo, showCluster, showContainer bool) string {
+	prefix := formatPodName(pod, showCluster)
+	if showContainer {
+		prefix += "/" + colorizeContainer(pod.Container)
+	}
+	return "[" + prefix + "]"
+}
+
+// clusterPodContainers expands a pod into its selected containers within a cluster
+func clusterPodContainers(cluster string, pod *corev1.Pod) []PodInfo {
+	podInfos := expandPodContainers(pod)
+	for i := range podInfos {
+		podInfos[i].Cluster = cluster
+	}
+	return podInfos
+}
+
+// streamKey identifies a single container log stream
+func streamKey(pod PodInfo) string {
+	return fmt.Sprintf("%s/%s/%s/%s", pod.Cluster, pod.Namespace, pod.Name, pod.Container)
+}
+
+// podMarker builds a marker naming a pod's namespace, name and container with format,
+// colored only in its Styled form so that published markers carry no escape codes
+func podMarker(pod PodInfo, format string) LogLine {
+	return LogLine{
+		PodInfo: pod,
+		Line:    fmt.Sprintf(format, pod.Namespace, pod.Name, pod.Container),
+		Styled:  fmt.Sprintf(format, colorizeNamespace(pod.Namespace), colorizePod(pod.Name), colorizeContainer(pod.Container)),
+	}
+}
+
+// streamPreviousLogs dumps the last lines of the previous, crashed instance of a container
+func streamPreviousLogs(clientset *kubernetes.Clientset, pod PodInfo, logChan chan<- LogLine, ctx context.Context) {
+	opts := &corev1.PodLogOptions{
+		Container:  pod.Container,
+		Previous:   true,
+		Timestamps: true,
+	}
+	if tailLines >= 0 {
+		opts.TailLines = int64Ptr(int64(tailLines))
+	}
+
+	stream, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create pod watcher for namespace %s: %v\n", namespace, err)
+		fmt.Fprintf(os.Stderr, "Failed to get previous logs for %s/%s (container: %s): %v\n", pod.Namespace, pod.Name, pod.Container, err)
 		return
 	}
-	defer watcher.Stop()
+	defer stream.Close()
 
-	for {
+	logChan <- LogLine{PodInfo: pod, Line: "=== Previous instance logs ==="}
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
 		select {
 		case <-ctx.Done():
 			return
-		case event, ok := <-watcher.ResultChan():
-			if !ok {
-				fmt.Fprintf(os.Stderr, "Pod watcher channel closed for namespace %s\n", namespace)
-				return
-			}
+		default:
+			ts, line, _ := splitTimestamp(scanner.Text())
+			logChan <- LogLine{PodInfo: pod, Time: ts, Line: line}
+		}
+	}
 
-			pod, ok := event.Object.(*corev1.Pod)
-			if !ok {
-				continue
-			}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading previous logs for %s/%s: %v\n", pod.Namespace, pod.Name, err)
+	}
 
-			podKey := fmt.Sprintf("%s/%s", namespace, pod.Name)
-
-			switch event.Type {
-			case "ADDED":
-				// New pod created, wait for it to be ready and start streaming its logs
-				fmt.Printf("New pod detected: %s/%s, waiting for container to be ready...\n",
-					colorizeNamespace(namespace), colorizePod(pod.Name))
-				go waitForPodAndStreamLogsWithTracking(clientset, PodInfo{
-					Namespace: namespace,
-					Name:      pod.Name,
-					Container: getContainerName(pod),
-				}, logChan, ctx, streamingPods, streamingMutex)
-			case "MODIFIED":
-				// Pod status changed, check if it's now ready
-				if pod.Status.Phase == corev1.PodRunning {
-					// Check if the specific container is ready
-					for _, containerStatus := range pod.Status.ContainerStatuses {
-						if containerStatus.Name == getContainerName(pod) && containerStatus.Ready {
-							streamingMutex.Lock()
-							if !(*streamingPods)[podKey] {
-								(*streamingPods)[podKey] = true
-								streamingMutex.Unlock()
-								fmt.Printf("Pod %s/%s is now ready, starting log stream...\n",
-									colorizeNamespace(namespace), colorizePod(pod.Name))
-								go streamPodLogs(clientset, PodInfo{
-									Namespace: namespace,
-									Name:      pod.Name,
-									Container: getContainerName(pod),
-								}, logChan, ctx)
-							} else {
-								streamingMutex.Unlock()
-							}
-							break
-						}
-					}
-				}
-			case "DELETED":
-				fmt.Printf("Pod deleted: %s/%s, stopping log stream...\n",
-					colorizeNamespace(namespace), colorizePod(pod.Name))
-				streamingMutex.Lock()
-				delete(*streamingPods, podKey)
-				streamingMutex.Unlock()
-			}
+	logChan <- LogLine{PodInfo: pod, Line: "=== End of previous instance logs ==="}
+}
+
+// Reconnect backoff bounds for dropped log streams
+const (
+	initialReconnectDelay = 1 * time.Second
+	maxReconnectDelay     = 30 * time.Second
+)
+
+// maxLogLineSize is the longest log line the scanner accepts
+const maxLogLineSize = 1024 * 1024
+
+// untilGracePeriod leaves time for lines logged just before --until to arrive
+const untilGracePeriod = 2 * time.Second
+
+// errUntilReached ends a stream once its lines pass the --until cut-off
+var errUntilReached = errors.New("reached the end of the time window")
+
+// followLogs reports whether streams should keep following new log lines.
+// There is nothing left to follow with --no-follow or once the --until cut-off lies in the past.
+func followLogs() bool {
+	if noFollow {
+		return false
+	}
+	return untilTime.IsZero() || untilTime.After(time.Now())
+}
+
+// streamsEnd reports whether every stream ends on its own, having read all of its
+// logs or passed the --until cut-off, so that ktail can exit once they are done
+func streamsEnd(watch bool) bool {
+	if watch {
+		return false
+	}
+	return !followLogs() || !untilTime.IsZero()
+}
+
+// streamPosition remembers the last line delivered from a log stream so that a
+// reconnect can resume from it without losing or duplicating lines
+type streamPosition struct {
+	lastTime time.Time
+	// seen counts the lines delivered with lastTime, replayed counts how many of
+	// them the current connection has passed again
+	seen     int
+	replayed int
+}
+
+// resume prepares the position for a new connection that replays from lastTime
+func (p *streamPosition) resume() {
+	p.replayed = 0
+}
+
+// accept reports whether a line stamped at ts has not been delivered yet and records it
+func (p *streamPosition) accept(ts time.Time) bool {
+	if ts.Before(p.lastTime) {
+		return false
+	}
+	if ts.Equal(p.lastTime) {
+		p.replayed++
+		if p.replayed <= p.seen {
+			return false
 		}
+		p.seen++
+		return true
 	}
+	p.lastTime = ts
+	p.seen = 1
+	p.replayed = 1
+	return true
 }
 
-// waitForPodAndStreamLogsWithTracking waits for a pod to be ready and starts streaming its logs
-func waitForPodAndStreamLogsWithTracking(clientset *kubernetes.Clientset, pod PodInfo, logChan chan<- LogLine, ctx context.Context, streamingPods *map[string]bool, streamingMutex *sync.Mutex) {
-	// Wait for pod to be ready
-	maxRetries := 30 // Wait up to 5 minutes (30 * 10 seconds)
-	retryCount := 0
+// streamPodLogs streams logs from a single pod, reconnecting with exponential backoff when the stream drops.
+// It returns an error when the stream failed for good; without follow, failures are not retried.
+func streamPodLogs(clientset *kubernetes.Clientset, pod PodInfo, logChan chan<- LogLine, ctx context.Context) error {
+	// Send header information for this pod
+	logChan <- podMarker(pod, "=== Starting logs for %s/%s (container: %s) ===")
 
-	podKey := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+	follow := followLogs()
+	if follow && !untilTime.IsZero() {
+		// Close the stream once the end of the time window has passed
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, untilTime.Add(untilGracePeriod))
+		defer cancel()
+	}
 
-	for retryCount < maxRetries {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			// Check if already streaming
-			streamingMutex.Lock()
-			if (*streamingPods)[podKey] {
-				streamingMutex.Unlock()
-				return
-			}
-			streamingMutex.Unlock()
+	var position streamPosition
+	delay := initialReconnectDelay
+	reconnecting := false
 
-			// Check pod status
-			podObj, err := clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	for {
+		received, err := streamPodLogsOnce(clientset, pod, logChan, ctx, &position, follow, reconnecting)
+		if ctx.Err() != nil || err == errUntilReached {
+			return nil
+		}
+		if !follow {
+			// All requested logs have been read, or reading them failed
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to get pod %s/%s: %v\n", pod.Namespace, pod.Name, err)
-				return
+				fmt.Fprintf(os.Stderr, "Error reading log stream for %s/%s: %v\n", pod.Namespace, pod.Name, err)
 			}
+			return err
+		}
+		if received {
+			delay = initialReconnectDelay
+		}
 
-			// Check if pod is running and container is ready
-			if podObj.Status.Phase == corev1.PodRunning {
-				// Check if the specific container is ready
-				for _, containerStatus := range podObj.Status.ContainerStatuses {
-					if containerStatus.Name == pod.Container && containerStatus.Ready {
-						streamingMutex.Lock()
-						if !(*streamingPods)[podKey] {
-							(*streamingPods)[podKey] = true
-							streamingMutex.Unlock()
-							fmt.Printf("Pod %s/%s is ready, starting log stream...\n",
-								colorizeNamespace(pod.Namespace), colorizePod(pod.Name))
-							streamPodLogs(clientset, pod, logChan, ctx)
-						} else {
-							streamingMutex.Unlock()
-						}
-						return
-					}
-				}
-			} else if podObj.Status.Phase == corev1.PodFailed || podObj.Status.Phase == corev1.PodSucceeded {
-				fmt.Printf("Pod %s/%s is in %s state, skipping log stream\n",
-					colorizeNamespace(pod.Namespace), colorizePod(pod.Name), podObj.Status.Phase)
-				return
+		if !shouldReconnect(clientset, pod, ctx) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading log stream for %s/%s: %v\n", pod.Namespace, pod.Name, err)
 			}
+			return err
+		}
 
-			// Wait 10 seconds before retrying
-			time.Sleep(10 * time.Second)
-			retryCount++
+		reason := "stream ended"
+		if err != nil {
+			reason = err.Error()
 		}
-	}
+		fmt.Fprintf(os.Stderr, "Log stream for %s/%s (container: %s) dropped (%s), reconnecting in %s...\n",
+			pod.Namespace, pod.Name, pod.Container, reason, delay)
 
-	fmt.Printf("Pod %s/%s did not become ready within timeout, skipping log stream\n",
-		colorizeNamespace(pod.Namespace), colorizePod(pod.Name))
-}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
 
-// streamPodLogs streams logs from a single pod
-func streamPodLogs(clientset *kubernetes.Clientset, pod PodInfo, logChan chan<- LogLine, ctx context.Context) {
-	// Send header information for this pod
-	logChan <- LogLine{
-		PodInfo: pod,
-		Line: fmt.Sprintf("=== Starting logs for %s/%s (container: %s) ===",
-			colorizeNamespace(pod.Namespace), colorizePod(pod.Name), colorizeContainer(pod.Container)),
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+		reconnecting = true
 	}
+}
 
-	req := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
-		Container: pod.Container,
-		Follow:    true,
-		TailLines: int64Ptr(int64(tailLines)),
-	})
+// streamPodLogsOnce opens a single log stream and forwards its lines until it ends.
+// It reports whether any new line was received along with the error that ended the stream.
+func streamPodLogsOnce(clientset *kubernetes.Clientset, pod PodInfo, logChan chan<- LogLine, ctx context.Context, position *streamPosition, follow, reconnecting bool) (bool, error) {
+	opts := &corev1.PodLogOptions{
+		Container:  pod.Container,
+		Follow:     follow,
+		Timestamps: true,
+	}
+	if position.lastTime.IsZero() {
+		applyTimeWindow(opts)
+	} else {
+		// SinceTime has second precision, already delivered lines are skipped below
+		sinceTime := metav1.NewTime(position.lastTime)
+		opts.SinceTime = &sinceTime
+	}
 
-	stream, err := req.Stream(ctx)
+	stream, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create log stream for %s/%s: %v\n", pod.Namespace, pod.Name, err)
-		return
+		return false, fmt.Errorf("failed to create log stream: %v", err)
 	}
 	defer stream.Close()
 
+	if reconnecting {
+		logChan <- podMarker(pod, "=== Reconnected to %s/%s (container: %s) ===")
+	}
+	position.resume()
+
+	received := false
 	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
 	for scanner.Scan() {
+		ts, line, ok := splitTimestamp(scanner.Text())
+		if ok && !untilTime.IsZero() && ts.After(untilTime) {
+			return received, errUntilReached
+		}
+		if ok && !position.accept(ts) {
+			continue
+		}
+		received = true
+
 		select {
 		case <-ctx.Done():
-			return
+			return received, nil
 		default:
 			logChan <- LogLine{
 				PodInfo: pod,
-				Line:    scanner.Text(),
+				Time:    ts,
+				Line:    line,
 			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil && err != io.EOF {
-		fmt.Fprintf(os.Stderr, "Error reading log stream for %s/%s: %v\n", pod.Namespace, pod.Name, err)
+		return received, err
+	}
+	return received, nil
+}
+
+// applyTimeWindow sets the tail and --since options used when a stream is first opened
+func applyTimeWindow(opts *corev1.PodLogOptions) {
+	if tailLines >= 0 {
+		opts.TailLines = int64Ptr(int64(tailLines))
+	}
+	if sinceDuration > 0 {
+		opts.SinceSeconds = int64Ptr(int64(sinceDuration.Seconds()))
+	} else if !sinceTime.IsZero() {
+		since := metav1.NewTime(sinceTime)
+		opts.SinceTime = &since
+	}
+}
+
+// shouldReconnect reports whether a dropped stream is worth reopening, which is
+// not the case once the pod is gone or has finished running, or the container
+// has terminated for good
+func shouldReconnect(clientset *kubernetes.Clientset, pod PodInfo, ctx context.Context) bool {
+	podObj, err := clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		// Transient API errors are retried, a missing pod is not
+		return !apierrors.IsNotFound(err)
+	}
+	if podObj.Status.Phase == corev1.PodSucceeded || podObj.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	return !containerFinished(podObj, pod.Container)
+}
+
+// containerFinished reports whether a container has terminated and will not run again:
+// an init container that completed, or any container the restart policy leaves stopped.
+// Sidecars, init containers with restartPolicy Always, are restarted like regular containers.
+func containerFinished(pod *corev1.Pod, name string) bool {
+	status := getContainerStatus(pod, name)
+	if status == nil || status.State.Terminated == nil {
+		return false
+	}
+	exitCode := status.State.Terminated.ExitCode
+
+	restartPolicy := pod.Spec.RestartPolicy
+	for _, c := range pod.Spec.InitContainers {
+		if c.Name != name {
+			continue
+		}
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			return false
+		}
+		if exitCode == 0 {
+			return true
+		}
+	}
+
+	switch restartPolicy {
+	case corev1.RestartPolicyNever:
+		return true
+	case corev1.RestartPolicyOnFailure:
+		return exitCode == 0
 	}
+	return false
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestFormatPrefix(t *testing.T) {
+	noColor = true
+	defer func() { noColor = false }()
+
+	pod := PodInfo{Cluster: "eu-west", Namespace: "shop", Name: "checkout-abc12", Container: "istio-proxy"}
+
+	tests := []struct {
+		name          string
+		showCluster   bool
+		showContainer bool
+		expected      string
+	}{
+		{name: "Namespace and pod", expected: "[shop/checkout-abc12]"},
+		{name: "With container", showContainer: true, expected: "[shop/checkout-abc12/istio-proxy]"},
+		{name: "With cluster", showCluster: true, expected: "[eu-west:shop/checkout-abc12]"},
+		{name: "With cluster and container", showCluster: true, showContainer: true, expected: "[eu-west:shop/checkout-abc12/istio-proxy]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatPrefix(pod, tt.showCluster, tt.showContainer); got != tt.expected {
+				t.Errorf("formatPrefix() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStreamKeyIncludesCluster(t *testing.T) {
+	a := PodInfo{Cluster: "eu", Namespace: "shop", Name: "checkout", Container: "app"}
+	b := PodInfo{Cluster: "us", Namespace: "shop", Name: "checkout", Container: "app"}
+
+	if streamKey(a) == streamKey(b) {
+		t.Errorf("streamKey() should differ across clusters, got %q for both", streamKey(a))
+	}
+}
+
+func TestStreamPositionSkipsReplayedLines(t *testing.T) {
+	base := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	t1 := base.Add(100 * time.Millisecond)
+	t2 := base.Add(200 * time.Millisecond)
+
+	var position streamPosition
+
+	// First connection delivers four lines, two of them sharing a timestamp
+	for i, ts := range []time.Time{base, t1, t2, t2} {
+		if !position.accept(ts) {
+			t.Fatalf("first connection: line %d at %v was rejected", i, ts)
+		}
+	}
+
+	// The reconnect replays from the start of the second, then continues with new lines
+	position.resume()
+	replay := []struct {
+		ts       time.Time
+		expected bool
+	}{
+		{ts: base, expected: false},
+		{ts: t1, expected: false},
+		{ts: t2, expected: false},
+		{ts: t2, expected: false},
+		{ts: t2, expected: true},
+		{ts: t2.Add(time.Millisecond), expected: true},
+	}
+	for i, tt := range replay {
+		if got := position.accept(tt.ts); got != tt.expected {
+			t.Errorf("reconnect: line %d at %v accepted = %v, want %v", i, tt.ts, got, tt.expected)
+		}
+	}
+}
+
+func TestContainerFinished(t *testing.T) {
+	always := corev1.ContainerRestartPolicyAlways
+	terminated := func(exitCode int32) corev1.ContainerState {
+		return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}}
+	}
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+
+	tests := []struct {
+		name          string
+		restartPolicy corev1.RestartPolicy
+		container     string
+		state         corev1.ContainerState
+		expected      bool
+	}{
+		{name: "Running container", restartPolicy: corev1.RestartPolicyAlways, container: "app", state: running},
+		{name: "Completed init container", restartPolicy: corev1.RestartPolicyAlways, container: "init-db", state: terminated(0), expected: true},
+		{name: "Failed init container is retried", restartPolicy: corev1.RestartPolicyAlways, container: "init-db", state: terminated(1)},
+		{name: "Failed init container without restarts", restartPolicy: corev1.RestartPolicyNever, container: "init-db", state: terminated(1), expected: true},
+		{name: "Exited sidecar init container is restarted", restartPolicy: corev1.RestartPolicyNever, container: "proxy", state: terminated(0)},
+		{name: "Exited container with restartPolicy Always", restartPolicy: corev1.RestartPolicyAlways, container: "app", state: terminated(0)},
+		{name: "Exited container with restartPolicy Never", restartPolicy: corev1.RestartPolicyNever, container: "app", state: terminated(1), expected: true},
+		{name: "Completed container with restartPolicy OnFailure", restartPolicy: corev1.RestartPolicyOnFailure, container: "app", state: terminated(0), expected: true},
+		{name: "Failed container with restartPolicy OnFailure", restartPolicy: corev1.RestartPolicyOnFailure, container: "app", state: terminated(2)},
+		{name: "Unknown container", restartPolicy: corev1.RestartPolicyNever, container: "missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				Spec: corev1.PodSpec{
+					RestartPolicy:  tt.restartPolicy,
+					InitContainers: []corev1.Container{{Name: "init-db"}, {Name: "proxy", RestartPolicy: &always}},
+					Containers:     []corev1.Container{{Name: "app"}},
+				},
+				Status: corev1.PodStatus{
+					InitContainerStatuses: []corev1.ContainerStatus{{Name: "init-db"}, {Name: "proxy"}},
+					ContainerStatuses:     []corev1.ContainerStatus{{Name: "app"}},
+				},
+			}
+			if status := getContainerStatus(pod, tt.container); status != nil {
+				status.State = tt.state
+			}
+			if got := containerFinished(pod, tt.container); got != tt.expected {
+				t.Errorf("containerFinished(%q) = %v, want %v", tt.container, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStreamsEnd(t *testing.T) {
+	defer func() { noFollow, untilTime = false, time.Time{} }()
+
+	tests := []struct {
+		name     string
+		watch    bool
+		noFollow bool
+		until    time.Time
+		want     bool
+	}{
+		{"follow", false, false, time.Time{}, false},
+		{"no follow", false, true, time.Time{}, true},
+		{"until in the past", false, false, time.Now().Add(-time.Minute), true},
+		{"until in the future", false, false, time.Now().Add(time.Minute), true},
+		{"watch", true, false, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		noFollow, untilTime = tt.noFollow, tt.until
+		if got := streamsEnd(tt.watch); got != tt.want {
+			t.Errorf("%s: streamsEnd() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
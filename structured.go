@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Supported --format values
+const (
+	formatRaw    = "raw"
+	formatPretty = "pretty"
+)
+
+// jsonIndent is the indentation used by --json-expand
+const jsonIndent = "    "
+
+// validateFormat checks that format is a supported --format value
+func validateFormat(format string) error {
+	switch format {
+	case formatRaw, formatPretty:
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (expected raw or pretty)", format)
+}
+
+// entryFormatter reshapes parsed log lines; lines no parser recognized pass through untouched
+type entryFormatter struct {
+	pretty bool
+	fields []string
+	expand bool
+}
+
+// newEntryFormatter creates a formatter, or returns nil when lines are printed as they are
+func newEntryFormatter(format string, fields []string, expand bool) *entryFormatter {
+	if format == formatRaw && len(fields) == 0 && !expand {
+		return nil
+	}
+	return &entryFormatter{pretty: format == formatPretty, fields: fields, expand: expand}
+}
+
+// render returns the formatted line, or the line itself when it was not parsed.
+// Without --format pretty, JSON lines stay JSON and other formats become key=value pairs.
+func (f *entryFormatter) render(line string, entry *logEntry) string {
+	switch {
+	case entry == nil:
+		return line
+	case f.pretty:
+		return f.renderPretty(entry)
+	case entry.Parser == "json":
+		return f.renderJSON(line, entry.Fields)
+	default:
+		return f.renderPairs(entry)
+	}
+}
+
+// renderJSON prints the entry as JSON, reduced to the selected fields and indented with --json-expand
+func (f *entryFormatter) renderJSON(line string, entry map[string]any) string {
+	compact := []byte(strings.TrimSpace(line))
+	if len(f.fields) > 0 {
+		var b bytes.Buffer
+		b.WriteByte('{')
+		for _, key := range f.fields {
+			value, ok := entry[key]
+			if !ok {
+				continue
+			}
+			if b.Len() > 1 {
+				b.WriteByte(',')
+			}
+			b.WriteString(marshalJSON(key))
+			b.WriteByte(':')
+			b.WriteString(marshalJSON(value))
+		}
+		b.WriteByte('}')
+		compact = b.Bytes()
+	}
+
+	if !f.expand {
+		return string(compact)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, compact, "", jsonIndent); err != nil {
+		return string(compact)
+	}
+	return indented.String()
+}
+
+// renderPretty prints the entry as "time LEVEL msg key=value...". With --fields
+// only the selected fields are shown, in the given order; with --json-expand
+// every other field goes on an indented line of its own.
+func (f *entryFormatter) renderPretty(entry *logEntry) string {
+	timeKey := entry.key(entryTimeKeys)
+	levelKey := entry.key(entryLevelKeys)
+	messageKey := entry.key(entryMessageKeys)
+
+	keys := f.fields
+	if len(keys) == 0 {
+		keys = []string{timeKey, levelKey, messageKey}
+		for _, key := range entry.Keys {
+			if key != timeKey && key != levelKey && key != messageKey {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	var head, details []string
+	for _, key := range keys {
+		value, ok := entry.Fields[key]
+		if key == "" || !ok {
+			continue
+		}
+		switch key {
+		case timeKey, messageKey:
+			head = append(head, plainFieldValue(value))
+		case levelKey:
+			head = append(head, strings.ToUpper(plainFieldValue(value)))
+		default:
+			if f.expand {
+				details = append(details, jsonIndent+key+": "+formatFieldValue(value, true))
+			} else {
+				head = append(head, key+"="+formatFieldValue(value, false))
+			}
+		}
+	}
+
+	return strings.Join(append([]string{strings.Join(head, " ")}, details...), "\n")
+}
+
+// renderPairs prints the entry as key=value pairs, one per indented line with --json-expand
+func (f *entryFormatter) renderPairs(entry *logEntry) string {
+	keys := f.fields
+	if len(keys) == 0 {
+		keys = entry.Keys
+	}
+
+	var pairs []string
+	for _, key := range keys {
+		value, ok := entry.Fields[key]
+		if !ok {
+			continue
+		}
+		if f.expand {
+			pairs = append(pairs, jsonIndent+key+": "+formatFieldValue(value, true))
+		} else {
+			pairs = append(pairs, key+"="+formatFieldValue(value, false))
+		}
+	}
+
+	if f.expand {
+		return strings.Join(pairs, "\n")
+	}
+	return strings.Join(pairs, " ")
+}
+
+// plainFieldValue renders a field value shown on its own, without quoting strings
+func plainFieldValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return formatFieldValue(value, false)
+}
+
+// formatFieldValue renders a field value; strings are quoted when they would be
+// ambiguous in key=value form, objects and arrays are JSON, indented when expanded
+func formatFieldValue(value any, expand bool) string {
+	switch v := value.(type) {
+	case string:
+		if expand || (v != "" && !strings.ContainsAny(v, " \t\"=")) {
+			return v
+		}
+		return strconv.Quote(v)
+	case json.Number:
+		return v.String()
+	case map[string]any, []any:
+		if expand {
+			indented, err := json.MarshalIndent(v, jsonIndent, jsonIndent)
+			if err == nil {
+				return string(indented)
+			}
+		}
+		return marshalJSON(v)
+	default:
+		return marshalJSON(v)
+	}
+}
+
+// marshalJSON encodes a decoded JSON value back to compact JSON
+func marshalJSON(value any) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,94 @@
+package main
+
+import "testing"
+
+func TestEntryFormatterRender(t *testing.T) {
+	line := `{"ts":"2024-03-01T09:00:00Z","level":"warn","msg":"slow query","duration_ms":1520,"db":{"name":"orders"},"user":"ada lovelace"}`
+
+	tests := []struct {
+		name   string
+		format string
+		fields []string
+		expand bool
+		line   string
+		want   string
+	}{
+		{
+			name:   "pretty",
+			format: formatPretty,
+			line:   line,
+			want:   `2024-03-01T09:00:00Z WARN slow query db={"name":"orders"} duration_ms=1520 user="ada lovelace"`,
+		},
+		{
+			name:   "pretty with fields",
+			format: formatPretty,
+			fields: []string{"msg", "duration_ms", "missing"},
+			line:   line,
+			want:   `slow query duration_ms=1520`,
+		},
+		{
+			name:   "pretty expanded",
+			format: formatPretty,
+			fields: []string{"level", "msg", "db"},
+			expand: true,
+			line:   line,
+			want:   "WARN slow query\n    db: {\n        \"name\": \"orders\"\n    }",
+		},
+		{
+			name:   "raw with fields",
+			format: formatRaw,
+			fields: []string{"msg", "duration_ms"},
+			line:   line,
+			want:   `{"msg":"slow query","duration_ms":1520}`,
+		},
+		{
+			name:   "raw expanded keeps key order",
+			format: formatRaw,
+			expand: true,
+			line:   `{"msg":"ok","code":200}`,
+			want:   "{\n    \"msg\": \"ok\",\n    \"code\": 200\n}",
+		},
+		{
+			name:   "pretty logfmt keeps the line order",
+			format: formatPretty,
+			line:   `ts=2024-03-01T09:00:00Z lvl=error msg="cache miss" key=user:42 attempt=3`,
+			want:   `2024-03-01T09:00:00Z ERROR cache miss key=user:42 attempt=3`,
+		},
+		{
+			name:   "raw logfmt with fields",
+			format: formatRaw,
+			fields: []string{"msg", "attempt"},
+			line:   `ts=2024-03-01T09:00:00Z lvl=error msg="cache miss" key=user:42 attempt=3`,
+			want:   `msg="cache miss" attempt=3`,
+		},
+		{
+			name:   "pretty nginx access log",
+			format: formatPretty,
+			fields: []string{"time_local", "level", "method", "path", "status"},
+			line:   `10.0.0.1 - - [01/Mar/2024:09:00:00 +0000] "GET /api/orders HTTP/1.1" 502 157 "-" "curl/8.0"`,
+			want:   `01/Mar/2024:09:00:00 +0000 ERROR method=GET path=/api/orders status=502`,
+		},
+		{
+			name:   "unparsed lines pass through",
+			format: formatPretty,
+			line:   "GET /healthz 200",
+			want:   "GET /healthz 200",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter := newEntryFormatter(tt.format, tt.fields, tt.expand)
+			entry := parseLogLine(tt.line, lineParsers)
+			if got := formatter.render(tt.line, entry); got != tt.want {
+				t.Errorf("render() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewEntryFormatterRaw(t *testing.T) {
+	if formatter := newEntryFormatter(formatRaw, nil, false); formatter != nil {
+		t.Errorf("newEntryFormatter(raw) = %v, want nil", formatter)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// templateLine is the data a --template is executed with for each log line
+type templateLine struct {
+	Cluster   string
+	Namespace string
+	Pod       string
+	Container string
+	Node      string
+	// Time is the timestamp formatted with --time-format and --tz, Timestamp the raw time
+	Time      string
+	Timestamp time.Time
+	// Level is the normalized severity (error, warn, info or debug), empty when unknown
+	Level string
+	// Message is the message field of a parsed line, or the whole line otherwise
+	Message string
+	Line    string
+	Fields  map[string]any
+	entry   *logEntry
+}
+
+// templateFuncs are the helper functions available in --template
+var templateFuncs = template.FuncMap{
+	"color":    templateColor,
+	"paint":    templatePaint,
+	"truncate": truncateText,
+	"pad":      padText,
+	"padLeft":  padTextLeft,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"json":     marshalJSON,
+}
+
+// parseLineTemplate compiles a --template
+func parseLineTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("line").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// lineRenderer renders log lines with a --template
+type lineRenderer struct {
+	tmpl       *template.Template
+	timestamps *timestampFormatter
+}
+
+// render executes the template for a log line
+func (r *lineRenderer) render(logLine LogLine) (string, error) {
+	data := templateLine{
+		Cluster:   logLine.PodInfo.Cluster,
+		Namespace: logLine.PodInfo.Namespace,
+		Pod:       logLine.PodInfo.Name,
+		Container: logLine.PodInfo.Container,
+		Node:      logLine.PodInfo.Node,
+		Time:      r.timestamps.format(logLine.Time),
+		Timestamp: logLine.Time,
+		Level:     lineLevel(logLine.Line, logLine.Entry),
+		Message:   logLine.Line,
+		Line:      logLine.Line,
+		entry:     logLine.Entry,
+	}
+	if logLine.Entry != nil {
+		data.Fields = logLine.Entry.Fields
+		if value, ok := logLine.Entry.Fields[logLine.Entry.key(entryMessageKeys)]; ok {
+			data.Message = plainFieldValue(value)
+		}
+	}
+
+	var b strings.Builder
+	if err := r.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Field returns a field of a parsed line, reaching into nested objects with dotted
+// names, or an empty string when the line has no such field: {{.Field "trace_id"}}
+func (l templateLine) Field(name string) string {
+	value, _ := lookupField(l.Line, l.entry, name)
+	return value
+}
+
+// templateColor colors a value by meaning: a level gets its severity color,
+// anything else, such as a pod name, a stable color of its own
+func templateColor(value string) string {
+	if level, ok := levelAliases[strings.ToLower(value)]; ok {
+		return colorize(value, scheme.levelColor(level))
+	}
+	return colorizeName(value, colorAuto)
+}
+
+// templatePaint colors text with a color spec as accepted by --colors: {{paint "bold+red" .Pod}}
+func templatePaint(spec, text string) (string, error) {
+	color, err := parseColor(spec)
+	if err != nil {
+		return "", err
+	}
+	return colorizeName(text, color), nil
+}
+
+// truncateText shortens text to at most n characters, marking the cut with an ellipsis
+func truncateText(n int, text string) string {
+	if n <= 0 || utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	if n == 1 {
+		return "…"
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// padText pads text with spaces on the right to at least n characters
+func padText(n int, text string) string {
+	if width := utf8.RuneCountInString(text); width < n {
+		return text + strings.Repeat(" ", n-width)
+	}
+	return text
+}
+
+// padTextLeft pads text with spaces on the left to at least n characters
+func padTextLeft(n int, text string) string {
+	if width := utf8.RuneCountInString(text); width < n {
+		return strings.Repeat(" ", n-width) + text
+	}
+	return text
+}
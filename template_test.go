@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLineRendererRender(t *testing.T) {
+	noColor = true
+	defer func() { noColor = false }()
+
+	timestamps, err := newTimestampFormatter(timeFormatShort, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := PodInfo{Cluster: "eu", Namespace: "shop", Name: "api-7d9f", Container: "app", Node: "node-a"}
+	ts := time.Date(2024, 3, 1, 9, 30, 15, 0, time.UTC)
+	jsonLine := `{"level":"warn","msg":"slow query","trace_id":"abc","http":{"status":504}}`
+
+	tests := []struct {
+		name     string
+		template string
+		line     string
+		want     string
+	}{
+		{"pod fields", `{{.Cluster}}:{{.Namespace}}/{{.Pod}}/{{.Container}}@{{.Node}}`, "plain", "eu:shop/api-7d9f/app@node-a"},
+		{"time and message", `{{.Time}} {{.Message}}`, "plain text", "09:30:15.000 plain text"},
+		{"parsed message and level", `{{upper .Level}} {{.Message}}`, jsonLine, "WARN slow query"},
+		{"detected level", `[{{.Level}}]`, "ERROR boom", "[error]"},
+		{"field access", `{{.Field "trace_id"}} {{.Field "http.status"}} {{.Field "missing"}}|`, jsonLine, "abc 504 |"},
+		{"fields map", `{{.Fields.trace_id}}`, jsonLine, "abc"},
+		{"padding", `{{.Pod | pad 10}}|{{padLeft 6 .Container}}`, "plain", "api-7d9f  |   app"},
+		{"truncation", `{{truncate 6 .Message}}`, "a rather long line", "a rat…"},
+		{"raw timestamp", `{{.Timestamp.Format "15:04"}}`, "plain", "09:30"},
+		{"json", `{{json .Message}}`, `say "hi"`, `"say \"hi\""`},
+		{"colors are plain without color", `{{color .Level}} {{paint "red" .Pod}}`, "ERROR boom", "error api-7d9f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseLineTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("parseLineTemplate(%q) error: %v", tt.template, err)
+			}
+			renderer := &lineRenderer{tmpl: tmpl, timestamps: timestamps}
+			got, err := renderer.render(LogLine{PodInfo: pod, Time: ts, Line: tt.line, Entry: parseLogLine(tt.line, lineParsers)})
+			if err != nil {
+				t.Fatalf("render() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateColor(t *testing.T) {
+	defer func() { scheme = colorSchemes["default"] }()
+	scheme = colorScheme{Error: "<e>"}
+
+	if got := templateColor("ERROR"); got != "<e>ERROR"+ColorReset {
+		t.Errorf("templateColor(ERROR) = %q", got)
+	}
+	if got := templateColor("api-1"); got != colorizeName("api-1", colorAuto) {
+		t.Errorf("templateColor(api-1) = %q, want the stable name color", got)
+	}
+	if _, err := templatePaint("purple", "x"); err == nil {
+		t.Error("templatePaint(purple) expected an error")
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		n    int
+		text string
+		want string
+	}{
+		{5, "short", "short"},
+		{4, "short", "sho…"},
+		{1, "short", "…"},
+		{0, "short", "short"},
+		{3, "한국어입니다", "한국…"},
+	}
+	for _, tt := range tests {
+		if got := truncateText(tt.n, tt.text); got != tt.want {
+			t.Errorf("truncateText(%d, %q) = %q, want %q", tt.n, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseLineTemplateErrors(t *testing.T) {
+	for _, text := range []string{`{{.Pod`, `{{nosuchfunc .Pod}}`} {
+		if _, err := parseLineTemplate(text); err == nil {
+			t.Errorf("parseLineTemplate(%q) expected an error", text)
+		}
+	}
+}
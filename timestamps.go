@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Supported --time-format values
+const (
+	timeFormatRFC3339  = "rfc3339"
+	timeFormatShort    = "short"
+	timeFormatRelative = "relative"
+	timeFormatUnix     = "unix"
+)
+
+// timeFormats lists the accepted --time-format values
+var timeFormats = []string{timeFormatRFC3339, timeFormatShort, timeFormatRelative, timeFormatUnix}
+
+// timestampFormatter renders log line timestamps for the output prefix
+type timestampFormatter struct {
+	layout   string
+	location *time.Location
+	// origin is the first timestamp printed, relative times are measured from it
+	origin time.Time
+}
+
+// newTimestampFormatter creates a formatter for one of the supported time formats
+func newTimestampFormatter(format string, location *time.Location) (*timestampFormatter, error) {
+	if err := validateTimeFormat(format); err != nil {
+		return nil, err
+	}
+	if location == nil {
+		location = time.Local
+	}
+	return &timestampFormatter{layout: format, location: location}, nil
+}
+
+// validateTimeFormat checks that format is a supported --time-format value
+func validateTimeFormat(format string) error {
+	for _, f := range timeFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown time format %q (expected one of %v)", format, timeFormats)
+}
+
+// loadTimeZone resolves a --tz value; "local" and an empty value mean the local time zone
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "" || name == "local" || name == "Local" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q: %v", name, err)
+	}
+	return location, nil
+}
+
+// format renders a timestamp; lines without one, such as banners, get an empty string
+func (f *timestampFormatter) format(ts time.Time) string {
+	if ts.IsZero() {
+		return ""
+	}
+
+	switch f.layout {
+	case timeFormatShort:
+		return ts.In(f.location).Format("15:04:05.000")
+	case timeFormatRelative:
+		if f.origin.IsZero() {
+			f.origin = ts
+		}
+		return fmt.Sprintf("%+.1fs", ts.Sub(f.origin).Seconds())
+	case timeFormatUnix:
+		return strconv.FormatInt(ts.UnixMilli(), 10)
+	default:
+		return ts.In(f.location).Format("2006-01-02T15:04:05.000Z07:00")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimestampFormatter(t *testing.T) {
+	seoul := time.FixedZone("KST", 9*60*60)
+	ts := time.Date(2024, 3, 1, 9, 30, 15, 250*int(time.Millisecond), time.UTC)
+
+	tests := []struct {
+		name     string
+		format   string
+		location *time.Location
+		want     string
+	}{
+		{"rfc3339 in UTC", timeFormatRFC3339, time.UTC, "2024-03-01T09:30:15.250Z"},
+		{"rfc3339 in another zone", timeFormatRFC3339, seoul, "2024-03-01T18:30:15.250+09:00"},
+		{"short", timeFormatShort, seoul, "18:30:15.250"},
+		{"unix milliseconds", timeFormatUnix, seoul, "1709285415250"},
+		{"relative to the first line", timeFormatRelative, time.UTC, "+0.0s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := newTimestampFormatter(tt.format, tt.location)
+			if err != nil {
+				t.Fatalf("newTimestampFormatter(%q) error: %v", tt.format, err)
+			}
+			if got := formatter.format(ts); got != tt.want {
+				t.Errorf("format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimestampFormatterRelative(t *testing.T) {
+	formatter, err := newTimestampFormatter(timeFormatRelative, time.UTC)
+	if err != nil {
+		t.Fatalf("newTimestampFormatter error: %v", err)
+	}
+
+	origin := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	formatter.format(origin)
+
+	if got := formatter.format(origin.Add(3200 * time.Millisecond)); got != "+3.2s" {
+		t.Errorf("format(origin+3.2s) = %q, want %q", got, "+3.2s")
+	}
+	if got := formatter.format(origin.Add(-500 * time.Millisecond)); got != "-0.5s" {
+		t.Errorf("format(origin-0.5s) = %q, want %q", got, "-0.5s")
+	}
+	if got := formatter.format(time.Time{}); got != "" {
+		t.Errorf("format(zero) = %q, want empty", got)
+	}
+}
+
+func TestNewTimestampFormatterRejectsUnknownFormat(t *testing.T) {
+	if _, err := newTimestampFormatter("iso", time.UTC); err == nil {
+		t.Error("newTimestampFormatter(\"iso\") expected an error")
+	}
+}
+
+func TestLoadTimeZone(t *testing.T) {
+	if loc, err := loadTimeZone("local"); err != nil || loc != time.Local {
+		t.Errorf("loadTimeZone(\"local\") = %v, %v, want time.Local", loc, err)
+	}
+	if loc, err := loadTimeZone("UTC"); err != nil || loc != time.UTC {
+		t.Errorf("loadTimeZone(\"UTC\") = %v, %v, want UTC", loc, err)
+	}
+	if _, err := loadTimeZone("Mars/Olympus"); err == nil {
+		t.Error("loadTimeZone(\"Mars/Olympus\") expected an error")
+	}
+}
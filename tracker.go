@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// informerResyncPeriod is how often the pod informer replays its cache to the handlers
+const informerResyncPeriod = 5 * time.Minute
+
+// podTracker reacts to pod events from a shared informer by starting, stopping
+// and annotating log streams in the registry. Informer handlers are invoked
+// sequentially, so its state needs no locking.
+type podTracker struct {
+	clientset *kubernetes.Clientset
+	target    WatchTarget
+	logChan   chan<- LogLine
+	ctx       context.Context
+	registry  *streamRegistry
+
+	// Last observed restart count per container, used to detect restarts and crash loops
+	restartCounts map[string]int32
+}
+
+// watchPodsWithTracking discovers pods of a watch target through a shared informer,
+// which relists and resumes on its own whenever the API server closes the watch
+func watchPodsWithTracking(clientset *kubernetes.Clientset, target WatchTarget, logChan chan<- LogLine, ctx context.Context, registry *streamRegistry) {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, informerResyncPeriod,
+		informers.WithNamespace(target.Namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = target.Selector
+		}),
+	)
+	podInformer := factory.Core().V1().Pods().Informer()
+
+	tracker := &podTracker{
+		clientset:     clientset,
+		target:        target,
+		logChan:       logChan,
+		ctx:           ctx,
+		registry:      registry,
+		restartCounts: make(map[string]int32),
+	}
+
+	_, err := podInformer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc:    tracker.onAdd,
+		UpdateFunc: tracker.onUpdate,
+		DeleteFunc: tracker.onDelete,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create pod watcher for %s: %v\n", namespaceLabel(target.Namespace), err)
+		return
+	}
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	if !cache.WaitForCacheSync(ctx.Done(), podInformer.HasSynced) {
+		if ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Failed to sync pod watcher for %s\n", namespaceLabel(target.Namespace))
+		}
+		return
+	}
+
+	<-ctx.Done()
+}
+
+// onAdd handles pods present at startup and newly created pods
+func (t *podTracker) onAdd(obj interface{}, isInInitialList bool) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	if !isInInitialList {
+		printStatus("New pod detected: %s/%s, waiting for container to be ready...\n",
+			colorizeNamespace(pod.Namespace), colorizePod(pod.Name))
+		if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
+			printStatus("Pod %s/%s is in %s state, skipping log stream\n",
+				colorizeNamespace(pod.Namespace), colorizePod(pod.Name), pod.Status.Phase)
+			return
+		}
+	}
+
+	t.sync(pod, "is ready")
+}
+
+// onUpdate handles pod status changes, including resyncs
+func (t *podTracker) onUpdate(oldObj, newObj interface{}) {
+	pod, ok := newObj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	t.sync(pod, "is now ready")
+}
+
+// onDelete stops the streams of a deleted pod
+func (t *podTracker) onDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	printStatus("Pod deleted: %s/%s, stopping log stream...\n",
+		colorizeNamespace(pod.Namespace), colorizePod(pod.Name))
+	t.registry.stopPod(t.target.Cluster, pod.Namespace, pod.Name)
+	for _, podInfo := range clusterPodContainers(t.target.Cluster, pod) {
+		delete(t.restartCounts, streamKey(podInfo))
+	}
+}
+
+// sync reports container restarts and starts streaming every container that is ready
+func (t *podTracker) sync(pod *corev1.Pod, readyMessage string) {
+	for _, podInfo := range clusterPodContainers(t.target.Cluster, pod) {
+		t.trackContainerRestarts(pod, podInfo)
+
+		if !isContainerReady(pod, podInfo.Container) {
+			continue
+		}
+		// A completed init container stays ready, its logs were already streamed to the end
+		if containerFinished(pod, podInfo.Container) && t.registry.wasAttached(podInfo) {
+			continue
+		}
+		if t.registry.start(t.clientset, podInfo) {
+			printStatus("Pod %s/%s (container: %s) %s, starting log stream...\n",
+				colorizeNamespace(pod.Namespace), colorizePod(pod.Name), colorizeContainer(podInfo.Container), readyMessage)
+		}
+	}
+}
+
+// trackContainerRestarts compares a container's restart count with the last one
+// seen and reports a restart inline, optionally dumping the crashed instance's logs.
+// The live stream itself reconnects to the new instance on its own.
+func (t *podTracker) trackContainerRestarts(pod *corev1.Pod, podInfo PodInfo) {
+	status := getContainerStatus(pod, podInfo.Container)
+	if status == nil {
+		return
+	}
+
+	key := streamKey(podInfo)
+	previous, known := t.restartCounts[key]
+	t.restartCounts[key] = status.RestartCount
+	if !known || status.RestartCount <= previous {
+		return
+	}
+
+	marker := LogLine{
+		PodInfo: podInfo,
+		Line:    fmt.Sprintf("=== Container %s restarted (restart #%d)", podInfo.Container, status.RestartCount),
+		Styled:  fmt.Sprintf("=== Container %s restarted (restart #%d)", colorizeContainer(podInfo.Container), status.RestartCount),
+	}
+	if terminated := status.LastTerminationState.Terminated; terminated != nil {
+		marker.Line += ": " + describeTermination(terminated)
+		marker.Styled += ": " + colorize(describeTermination(terminated), scheme.Error)
+	}
+	marker.Line += " ==="
+	marker.Styled += " ==="
+
+	go func() {
+		t.logChan <- marker
+		if showPrevious {
+			streamPreviousLogs(t.clientset, podInfo, t.logChan, t.ctx)
+		}
+	}()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodTrackerSkipsFinishedInitContainer(t *testing.T) {
+	defer func() { initContainers = false }()
+	initContainers = true
+
+	registry := newStreamRegistry(context.Background(), make(chan LogLine, 10))
+	tracker := &podTracker{
+		target:        WatchTarget{Cluster: "eu", Namespace: "shop"},
+		logChan:       make(chan LogLine, 10),
+		ctx:           context.Background(),
+		registry:      registry,
+		restartCounts: make(map[string]int32),
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "checkout-1"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "migrate"}},
+			Containers:     []corev1.Container{{Name: "app"}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			InitContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "migrate",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"}},
+			}},
+		},
+	}
+
+	// The init container's stream ran to its end and was removed from the registry
+	migrate := PodInfo{Cluster: "eu", Namespace: "shop", Name: "checkout-1", Container: "migrate"}
+	addTestStream(registry, migrate)
+	registry.remove(streamKey(migrate), registry.streams[streamKey(migrate)])
+
+	// Later updates and resyncs still report it terminated
+	tracker.onUpdate(pod, pod)
+	tracker.onUpdate(pod, pod)
+
+	if _, ok := registry.streams[streamKey(migrate)]; ok {
+		t.Errorf("onUpdate() attached the finished init container again")
+	}
+	if started := registry.startedCount(); started != 0 {
+		t.Errorf("onUpdate() started %d streams, want 0", started)
+	}
+
+	// A pod of the same name created after a deletion is attached afresh
+	registry.stopPod("eu", "shop", "checkout-1")
+	if registry.wasAttached(migrate) {
+		t.Errorf("stopPod() kept the finished init container of the deleted pod")
+	}
+}
@@ -1,15 +1,42 @@
 package main
 
+import (
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClusterClient pairs a Kubernetes client with the name of the context it was created from
+type ClusterClient struct {
+	Name      string
+	Clientset *kubernetes.Clientset
+}
+
 // PodInfo represents information about a Kubernetes pod
 type PodInfo struct {
+	Cluster   string
 	Namespace string
 	Name      string
 	Container string
+	Node      string
 	Status    string
 }
 
-// LogLine represents a log line with associated pod information
+// LogLine represents a log line with associated pod information.
+// Time is the kubelet timestamp of the line, zero for ktail's own markers.
+// Entry holds the fields of the line when one of the parsers recognized it.
+// Line is always plain text; Styled is a marker's colored form for the terminal.
 type LogLine struct {
 	PodInfo PodInfo
+	Time    time.Time
 	Line    string
+	Styled  string
+	Entry   *logEntry
+}
+
+// WatchTarget describes the namespace and label selector used to discover pods
+type WatchTarget struct {
+	Cluster   string
+	Namespace string
+	Selector  string
 }
@@ -1,44 +1,200 @@
 package main
 
 import (
+	"fmt"
+	"hash/fnv"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // ANSI color codes
 const (
-	ColorReset  = "\033[0m"
-	ColorGreen  = "\033[32m"
-	ColorYellow = "\033[33m"
-	ColorBlue   = "\033[34m"
-	ColorRed    = "\033[31m"
-	ColorCyan   = "\033[36m"
+	ColorReset   = "\033[0m"
+	ColorGreen   = "\033[32m"
+	ColorYellow  = "\033[33m"
+	ColorBlue    = "\033[34m"
+	ColorRed     = "\033[31m"
+	ColorCyan    = "\033[36m"
+	ColorMagenta = "\033[35m"
+	ColorGray    = "\033[90m"
+
+	// ColorHighlight marks the parts of a line matched by an include pattern
+	ColorHighlight = "\033[1;31m"
 )
 
+// clusterColors is the palette used to tell clusters apart
+var clusterColors = []string{ColorYellow, ColorBlue, ColorMagenta, ColorRed, ColorCyan}
+
+// assignedClusterColors holds the colors of the clusters being tailed, by cluster name
+var assignedClusterColors = map[string]string{}
+
 // int64Ptr returns a pointer to an int64 value
 func int64Ptr(i int64) *int64 { return &i }
 
 // colorize returns colored text if colors are enabled, otherwise returns plain text
 func colorize(text, color string) string {
-	if noColor {
+	if noColor || color == "" || text == "" {
 		return text
 	}
 	return color + text + ColorReset
 }
 
+// colorForName picks a stable color for a name from the given palette
+func colorForName(name string, palette []string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return palette[h.Sum32()%uint32(len(palette))]
+}
+
+// assignClusterColors gives the clusters the palette colors in order so that no two
+// of them share a color; clusters beyond the palette get a color picked by name
+func assignClusterColors(clusters []ClusterClient) {
+	assignedClusterColors = make(map[string]string)
+	for i, cluster := range clusters {
+		if i >= len(clusterColors) {
+			break
+		}
+		assignedClusterColors[cluster.Name] = clusterColors[i]
+	}
+}
+
+// colorizeCluster returns colored cluster name text, stable per cluster
+func colorizeCluster(cluster string) string {
+	color, ok := assignedClusterColors[cluster]
+	if !ok {
+		color = colorForName(cluster, clusterColors)
+	}
+	return colorize(cluster, color)
+}
+
 // colorizeNamespace returns colored namespace text
 func colorizeNamespace(namespace string) string {
-	return colorize(namespace, ColorGreen)
+	return colorizeName(namespace, scheme.Namespace)
 }
 
-// colorizePod returns colored pod name text
+// colorizePod returns colored pod name text, stable per pod by default
 func colorizePod(pod string) string {
-	return colorize(pod, ColorGreen)
+	return colorizeName(pod, scheme.Pod)
 }
 
-// colorizeContainer returns colored container name text
+// colorizeTimestamp returns dimmed timestamp text
+func colorizeTimestamp(timestamp string) string {
+	return colorize(timestamp, scheme.Timestamp)
+}
+
+// colorizeContainer returns colored container name text, stable per container by default
 func colorizeContainer(container string) string {
-	return colorize(container, ColorCyan)
+	return colorizeName(container, scheme.Container)
+}
+
+// formatPodName returns the colored namespace/pod name, prefixed by the cluster when requested
+func formatPodName(pod PodInfo, showCluster bool) string {
+	name := colorizeNamespace(pod.Namespace) + "/" + colorizePod(pod.Name)
+	if showCluster {
+		name = colorizeCluster(pod.Cluster) + ":" + name
+	}
+	return name
+}
+
+// printStatus writes a status message. It goes to stderr when stdout carries
+// JSON output, so that only log records reach tools reading stdout.
+func printStatus(format string, args ...any) {
+	out := os.Stdout
+	if outputMode == outputJSON {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// namespaceLabel returns a human readable description of a namespace scope
+func namespaceLabel(namespace string) string {
+	if namespace == "" {
+		return "all namespaces"
+	}
+	return "namespace " + namespace
+}
+
+// countNamespaces returns the number of distinct namespaces among the given pods
+func countNamespaces(pods []PodInfo) int {
+	namespaces := make(map[string]bool)
+	for _, pod := range pods {
+		namespaces[pod.Namespace] = true
+	}
+	return len(namespaces)
+}
+
+// splitCommaList splits a comma separated flag value, trimming blanks and dropping duplicates
+func splitCommaList(value string) []string {
+	var items []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		items = append(items, item)
+	}
+	return items
+}
+
+// isInteractive reports whether stdin is attached to a terminal
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// splitTimestamp splits the RFC3339Nano timestamp the API server prepends to a
+// log line when timestamps are requested from the rest of the line
+func splitTimestamp(line string) (time.Time, string, bool) {
+	stamp, rest, found := strings.Cut(line, " ")
+	ts, err := time.Parse(time.RFC3339Nano, stamp)
+	if err != nil {
+		return time.Time{}, line, false
+	}
+	if !found {
+		// An empty log line carries only the timestamp
+		return ts, "", true
+	}
+	return ts, rest, true
+}
+
+// parseTimeArg parses an absolute or relative point in time. Accepted forms are
+// RFC3339 timestamps, local "2006-01-02 15:04:05" dates, local "15:04[:05]" times
+// of today, "now", and durations before now such as "15m", "-15m" or "15m ago".
+func parseTimeArg(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "now" {
+		return now, nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location()), nil
+		}
+	}
+
+	relative := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(value, "-"), "ago"))
+	if d, err := time.ParseDuration(relative); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339, a local time like 09:00 or a duration like 15m", value)
 }
 
 // parseCustomFlags parses custom flags like -1000f, -500f, etc.
@@ -4,6 +4,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestParseCustomFlags(t *testing.T) {
@@ -220,3 +221,177 @@ func BenchmarkParseCustomFlags(b *testing.B) {
 		parseCustomFlags()
 	}
 }
+
+func TestSplitCommaList(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+	}{
+		{name: "Empty value", value: "", expected: nil},
+		{name: "Single item", value: "default", expected: []string{"default"}},
+		{name: "Multiple items", value: "gateway,app,worker", expected: []string{"gateway", "app", "worker"}},
+		{name: "Whitespace and blanks", value: " gateway , ,app,", expected: []string{"gateway", "app"}},
+		{name: "Duplicates removed", value: "app,worker,app", expected: []string{"app", "worker"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := splitCommaList(tt.value)
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("splitCommaList(%q) = %v, want %v", tt.value, actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCountNamespaces(t *testing.T) {
+	pods := []PodInfo{
+		{Namespace: "gateway", Name: "gw-1"},
+		{Namespace: "app", Name: "app-1"},
+		{Namespace: "app", Name: "app-2"},
+	}
+
+	if got := countNamespaces(pods); got != 2 {
+		t.Errorf("countNamespaces() = %d, want 2", got)
+	}
+	if got := countNamespaces(nil); got != 0 {
+		t.Errorf("countNamespaces(nil) = %d, want 0", got)
+	}
+}
+
+func TestColorForName(t *testing.T) {
+	first := colorForName("eu-west", clusterColors)
+	for i := 0; i < 10; i++ {
+		if got := colorForName("eu-west", clusterColors); got != first {
+			t.Fatalf("colorForName() is not stable: got %q, then %q", first, got)
+		}
+	}
+
+	found := false
+	for _, color := range clusterColors {
+		if color == first {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("colorForName() = %q, not part of the palette", first)
+	}
+}
+
+func TestAssignClusterColors(t *testing.T) {
+	defer func() { assignedClusterColors = map[string]string{} }()
+
+	var clusters []ClusterClient
+	for _, name := range []string{"eu-west", "us-east", "ap-south", "eu-north", "us-west", "sa-east"} {
+		clusters = append(clusters, ClusterClient{Name: name})
+	}
+	assignClusterColors(clusters)
+
+	// The palette colors are distinct, so the first clusters never share one
+	for i, cluster := range clusters[:len(clusterColors)] {
+		want := clusterColors[i] + cluster.Name + ColorReset
+		if got := colorizeCluster(cluster.Name); got != want {
+			t.Errorf("colorizeCluster(%q) = %q, want %q", cluster.Name, got, want)
+		}
+	}
+
+	// Clusters beyond the palette fall back to a color picked by name
+	last := clusters[len(clusters)-1].Name
+	if got, want := colorizeCluster(last), colorForName(last, clusterColors)+last+ColorReset; got != want {
+		t.Errorf("colorizeCluster(%q) = %q, want %q", last, got, want)
+	}
+}
+
+func TestSplitTimestamp(t *testing.T) {
+	tests := []struct {
+		name         string
+		line         string
+		expectedTime time.Time
+		expectedLine string
+		expectedOK   bool
+	}{
+		{
+			name:         "Nanosecond timestamp",
+			line:         "2024-05-01T09:00:00.123456789Z GET /api/orders 200",
+			expectedTime: time.Date(2024, 5, 1, 9, 0, 0, 123456789, time.UTC),
+			expectedLine: "GET /api/orders 200",
+			expectedOK:   true,
+		},
+		{
+			name:         "Second precision timestamp",
+			line:         "2024-05-01T09:00:00Z started",
+			expectedTime: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC),
+			expectedLine: "started",
+			expectedOK:   true,
+		},
+		{
+			name:         "Empty log line",
+			line:         "2024-05-01T09:00:00Z",
+			expectedTime: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC),
+			expectedLine: "",
+			expectedOK:   true,
+		},
+		{
+			name:         "No timestamp",
+			line:         "plain log line",
+			expectedLine: "plain log line",
+		},
+		{
+			name:         "Empty string",
+			line:         "",
+			expectedLine: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, line, ok := splitTimestamp(tt.line)
+			if ok != tt.expectedOK || line != tt.expectedLine || !ts.Equal(tt.expectedTime) {
+				t.Errorf("splitTimestamp(%q) = (%v, %q, %v), want (%v, %q, %v)",
+					tt.line, ts, line, ok, tt.expectedTime, tt.expectedLine, tt.expectedOK)
+			}
+		})
+	}
+}
+
+func TestParseTimeArg(t *testing.T) {
+	loc := time.FixedZone("KST", 9*60*60)
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, loc)
+
+	tests := []struct {
+		value       string
+		expected    time.Time
+		expectError bool
+	}{
+		{value: "now", expected: now},
+		{value: "2024-05-01T09:00:00Z", expected: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)},
+		{value: "2024-05-01T09:00:00.5+09:00", expected: time.Date(2024, 5, 1, 9, 0, 0, 500000000, loc)},
+		{value: "2024-04-30 23:15:00", expected: time.Date(2024, 4, 30, 23, 15, 0, 0, loc)},
+		{value: "09:00", expected: time.Date(2024, 5, 1, 9, 0, 0, 0, loc)},
+		{value: "09:05:30", expected: time.Date(2024, 5, 1, 9, 5, 30, 0, loc)},
+		{value: "15m", expected: now.Add(-15 * time.Minute)},
+		{value: "-1h30m", expected: now.Add(-90 * time.Minute)},
+		{value: "2h ago", expected: now.Add(-2 * time.Hour)},
+		{value: "yesterday", expectError: true},
+		{value: "", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			actual, err := parseTimeArg(tt.value, now)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("parseTimeArg(%q) expected error, got %v", tt.value, actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTimeArg(%q) unexpected error: %v", tt.value, err)
+			}
+			if !actual.Equal(tt.expected) {
+				t.Errorf("parseTimeArg(%q) = %v, want %v", tt.value, actual, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Canonical workload kinds that can be resolved to pods
+const (
+	KindDeployment  = "deployment"
+	KindStatefulSet = "statefulset"
+	KindDaemonSet   = "daemonset"
+	KindReplicaSet  = "replicaset"
+	KindJob         = "job"
+	KindCronJob     = "cronjob"
+)
+
+// workloadKindAliases maps kubectl style resource names to canonical workload kinds
+var workloadKindAliases = map[string]string{
+	"deploy":       KindDeployment,
+	"deployment":   KindDeployment,
+	"deployments":  KindDeployment,
+	"sts":          KindStatefulSet,
+	"statefulset":  KindStatefulSet,
+	"statefulsets": KindStatefulSet,
+	"ds":           KindDaemonSet,
+	"daemonset":    KindDaemonSet,
+	"daemonsets":   KindDaemonSet,
+	"rs":           KindReplicaSet,
+	"replicaset":   KindReplicaSet,
+	"replicasets":  KindReplicaSet,
+	"job":          KindJob,
+	"jobs":         KindJob,
+	"cj":           KindCronJob,
+	"cronjob":      KindCronJob,
+	"cronjobs":     KindCronJob,
+}
+
+// parseWorkloadRef splits a reference like deploy/checkout into its canonical kind and name
+func parseWorkloadRef(ref string) (string, string, error) {
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid workload %q, expected TYPE/NAME (e.g. deploy/checkout)", ref)
+	}
+
+	kind, ok := workloadKindAliases[strings.ToLower(parts[0])]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported workload type %q in %q", parts[0], ref)
+	}
+
+	return kind, parts[1], nil
+}
+
+// getWorkloadSelector retrieves a workload and returns the label selector matching its pods
+func getWorkloadSelector(clientset *kubernetes.Clientset, namespace, ref string) (string, error) {
+	kind, name, err := parseWorkloadRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	ctx := context.TODO()
+	var selector *metav1.LabelSelector
+	var templateLabels map[string]string
+
+	switch kind {
+	case KindDeployment:
+		obj, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get deployment %s: %v", name, err)
+		}
+		selector = obj.Spec.Selector
+	case KindStatefulSet:
+		obj, err := clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get statefulset %s: %v", name, err)
+		}
+		selector = obj.Spec.Selector
+	case KindDaemonSet:
+		obj, err := clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get daemonset %s: %v", name, err)
+		}
+		selector = obj.Spec.Selector
+	case KindReplicaSet:
+		obj, err := clientset.AppsV1().ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get replicaset %s: %v", name, err)
+		}
+		selector = obj.Spec.Selector
+	case KindJob:
+		obj, err := clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get job %s: %v", name, err)
+		}
+		selector = obj.Spec.Selector
+		templateLabels = obj.Spec.Template.Labels
+	case KindCronJob:
+		// Jobs spawned by a CronJob get generated selectors, so match on the pod template labels
+		obj, err := clientset.BatchV1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get cronjob %s: %v", name, err)
+		}
+		templateLabels = obj.Spec.JobTemplate.Spec.Template.Labels
+	}
+
+	if selector != nil {
+		sel, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return "", fmt.Errorf("invalid selector on %s: %v", ref, err)
+		}
+		if !sel.Empty() {
+			return sel.String(), nil
+		}
+	}
+
+	if len(templateLabels) == 0 {
+		return "", fmt.Errorf("%s has no pod selector or template labels", ref)
+	}
+	return labels.SelectorFromSet(templateLabels).String(), nil
+}
+
+// mergeSelectors combines label selectors so that pods must match all of them
+func mergeSelectors(selectors ...string) string {
+	var parts []string
+	for _, s := range selectors {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// resolveWorkloadPods finds the current pods of the given workloads and the watch
+// targets that keep following them, e.g. as a Deployment rolls over to a new ReplicaSet
+func resolveWorkloadPods(clientset *kubernetes.Clientset, namespaces []string, refs []string) ([]PodInfo, []WatchTarget, error) {
+	var podInfos []PodInfo
+	var targets []WatchTarget
+
+	for _, ns := range namespaces {
+		if ns == metav1.NamespaceAll {
+			return nil, nil, fmt.Errorf("workloads cannot be resolved across all namespaces")
+		}
+
+		for _, ref := range refs {
+			workloadSelector, err := getWorkloadSelector(clientset, ns, ref)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			target := WatchTarget{Namespace: ns, Selector: mergeSelectors(workloadSelector, selector)}
+			pods, err := getAllPods(clientset, []string{ns}, target.Selector)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			podInfos = append(podInfos, pods...)
+			targets = append(targets, target)
+		}
+	}
+
+	return podInfos, targets, nil
+}
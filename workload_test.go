@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestParseWorkloadRef(t *testing.T) {
+	tests := []struct {
+		ref          string
+		expectedKind string
+		expectedName string
+		expectError  bool
+	}{
+		{ref: "deploy/checkout", expectedKind: KindDeployment, expectedName: "checkout"},
+		{ref: "Deployment/checkout", expectedKind: KindDeployment, expectedName: "checkout"},
+		{ref: "sts/kafka", expectedKind: KindStatefulSet, expectedName: "kafka"},
+		{ref: "ds/node-exporter", expectedKind: KindDaemonSet, expectedName: "node-exporter"},
+		{ref: "rs/checkout-7d4f8b9c6", expectedKind: KindReplicaSet, expectedName: "checkout-7d4f8b9c6"},
+		{ref: "jobs/migrate", expectedKind: KindJob, expectedName: "migrate"},
+		{ref: "cj/cleanup", expectedKind: KindCronJob, expectedName: "cleanup"},
+		{ref: "checkout", expectError: true},
+		{ref: "deploy/", expectError: true},
+		{ref: "/checkout", expectError: true},
+		{ref: "svc/checkout", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			kind, name, err := parseWorkloadRef(tt.ref)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("parseWorkloadRef(%q) expected error, got %s/%s", tt.ref, kind, name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseWorkloadRef(%q) unexpected error: %v", tt.ref, err)
+			}
+			if kind != tt.expectedKind || name != tt.expectedName {
+				t.Errorf("parseWorkloadRef(%q) = %s/%s, want %s/%s", tt.ref, kind, name, tt.expectedKind, tt.expectedName)
+			}
+		})
+	}
+}
+
+func TestMergeSelectors(t *testing.T) {
+	tests := []struct {
+		selectors []string
+		expected  string
+	}{
+		{selectors: nil, expected: ""},
+		{selectors: []string{"app=checkout", ""}, expected: "app=checkout"},
+		{selectors: []string{"", "tier!=canary"}, expected: "tier!=canary"},
+		{selectors: []string{"app=checkout", "tier!=canary"}, expected: "app=checkout,tier!=canary"},
+	}
+
+	for _, tt := range tests {
+		if got := mergeSelectors(tt.selectors...); got != tt.expected {
+			t.Errorf("mergeSelectors(%q) = %q, want %q", tt.selectors, got, tt.expected)
+		}
+	}
+}